@@ -0,0 +1,149 @@
+package gcl
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+//
+// Workload benchmarks of LazyList and Skiplist, run with
+//
+//	go test -run XXX -bench Workload -benchmem
+//
+// Each reports ops/s and the p99 latency of a sample of operations, over
+// a fixed number of goroutines.
+//
+
+type workload struct {
+	name string
+	// percent of lookups, the rest are split between add and remove.
+	readPct int
+}
+
+var workloads = []workload{
+	{"read", 95},
+	{"mixed", 50},
+	{"write", 10},
+}
+
+// latencies of every sampleEvery-th operation are recorded.
+const sampleEvery = 16
+
+func benchWorkload(b *testing.B, list listAPI, kRange, g int, w workload) {
+	for i := 0; i < kRange; i += 2 {
+		list.Add(i, i)
+	}
+	samples := make([][]time.Duration, g)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for i := 0; i < g; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			for j := i; j < b.N; j += g {
+				k := r.Intn(kRange)
+				op := r.Intn(100)
+				var start time.Time
+				sample := j%sampleEvery == 0
+				if sample {
+					start = time.Now()
+				}
+				switch {
+				case op < w.readPct:
+					list.Lookup(k)
+				case op%2 == 0:
+					list.Add(k, k)
+				default:
+					list.Remove(k)
+				}
+				if sample {
+					samples[i] = append(samples[i], time.Since(start))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	all := slices.Concat(samples...)
+	if len(all) > 0 {
+		slices.Sort(all)
+		b.ReportMetric(float64(all[len(all)*99/100].Nanoseconds()), "p99-ns")
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "ops/s")
+}
+
+func BenchmarkWorkload(b *testing.B) {
+	lists := []struct {
+		name   string
+		mk     func() listAPI
+		counts []int
+	}{
+		// a lazy list is O(n), keep it short.
+		{"lazylist", func() listAPI { return NewOrderedLazyList[int, int]() }, []int{100, 1000}},
+		{"skiplist", func() listAPI { return NewOrderedSkipList[int, int](WithExpectedSize(1 << 20)) }, []int{1000, 1 << 20}},
+	}
+	for _, l := range lists {
+		for _, kRange := range l.counts {
+			for _, g := range []int{1, 8, 64} {
+				for _, w := range workloads {
+					b.Run(fmt.Sprintf("%s-%d-g%d-%s", l.name, kRange, g, w.name), func(b *testing.B) {
+						benchWorkload(b, l.mk(), kRange, g, w)
+					})
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkLookupMany looks up a sorted batch of keys per op, with
+// LookupMany or with a Lookup per key.
+func BenchmarkLookupMany(b *testing.B) {
+	const (
+		kRange = 1 << 16
+		batch  = 64
+	)
+	type batchAPI interface {
+		listAPI
+		LookupMany(keys []int) ([]int, []bool)
+	}
+	lists := []struct {
+		name string
+		list batchAPI
+	}{
+		{"lazylist", NewOrderedLazyList[int, int]()},
+		{"skiplist", NewOrderedSkipList[int, int](WithExpectedSize(kRange))},
+	}
+	for _, l := range lists {
+		for i := 0; i < kRange; i += 2 {
+			l.list.Add(i, i)
+		}
+		keys := make([]int, batch)
+		for _, many := range []bool{true, false} {
+			b.Run(fmt.Sprintf("%s-many-%v", l.name, many), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					// a request's keys, close together.
+					base := rand.Intn(kRange - 4*batch)
+					for j := range keys {
+						keys[j] = base + rand.Intn(4*batch)
+					}
+					slices.Sort(keys)
+					if many {
+						l.list.LookupMany(keys)
+					} else {
+						for _, k := range keys {
+							l.list.Lookup(k)
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package gcl
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+//
+// BlockingRing is a bounded queue between goroutines, a RingBuffer
+// guarded by a mutex.   Waiters wait on channels, closed to wake them all
+// when the ring changes, so that a wait can also select on a context.
+//
+
+// ErrRingClosed is returned by operations on a closed ring.
+var ErrRingClosed = errors.New("ring buffer closed")
+
+// FullPolicy says what Put does when the ring is full.
+type FullPolicy int
+
+const (
+	// FullBlock waits for room.
+	FullBlock FullPolicy = iota
+	// FullDropNewest drops the value put.
+	FullDropNewest
+	// FullOverwrite overwrites the oldest value, as RingBuffer.Push.
+	FullOverwrite
+)
+
+type BlockingRing[T any] struct {
+	mu     sync.Mutex
+	rb     *RingBuffer[T]
+	size   int
+	policy FullPolicy
+	closed bool
+	// values dropped or overwritten.
+	dropped uint64
+	// channels of waiters, nil if there are none.
+	notEmpty, notFull chan struct{}
+}
+
+// NewBlockingRing creates a ring of size values, with policy for a full
+// ring.
+func NewBlockingRing[T any](size int, policy FullPolicy) *BlockingRing[T] {
+	MustCheck(size > 0, "ring buffer size must be positive")
+	return &BlockingRing[T]{rb: NewRingBuffer[T](size), size: size, policy: policy}
+}
+
+// waitCh returns the channel *ch, creating it if needed.   The lock
+// guarding *ch must be held.
+func waitCh(ch *chan struct{}) chan struct{} {
+	if *ch == nil {
+		*ch = make(chan struct{})
+	}
+	return *ch
+}
+
+// wakeCh wakes all waiters on *ch, with its lock held.
+func wakeCh(ch *chan struct{}) {
+	if *ch != nil {
+		close(*ch)
+		*ch = nil
+	}
+}
+
+// put puts v if it can without waiting, br.mu must be held.   It returns
+// if v was put or dropped, and if it was put.
+func (br *BlockingRing[T]) put(v T) (done, ok bool) {
+	if br.rb.Len() < br.size {
+		br.rb.Push(v)
+		wakeCh(&br.notEmpty)
+		return true, true
+	}
+	switch br.policy {
+	case FullDropNewest:
+		br.dropped++
+		return true, false
+	case FullOverwrite:
+		br.dropped++
+		br.rb.Push(v)
+		return true, true
+	}
+	return false, false
+}
+
+// Put puts v into the ring.   When the ring is full, it waits for room,
+// until ctx is done, or drops or overwrites a value as the policy says.
+// It returns ErrRingClosed if the ring is closed, or the error of ctx.
+func (br *BlockingRing[T]) Put(ctx context.Context, v T) error {
+	br.mu.Lock()
+	for {
+		if br.closed {
+			br.mu.Unlock()
+			return ErrRingClosed
+		}
+		if done, _ := br.put(v); done {
+			br.mu.Unlock()
+			return nil
+		}
+		ch := waitCh(&br.notFull)
+		br.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		br.mu.Lock()
+	}
+}
+
+// TryPut puts v without waiting, it returns false if v was not put, the
+// ring is closed, or full and the policy is not FullOverwrite.
+func (br *BlockingRing[T]) TryPut(v T) bool {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.closed {
+		return false
+	}
+	_, ok := br.put(v)
+	return ok
+}
+
+// take takes the oldest value if there is one, br.mu must be held.
+func (br *BlockingRing[T]) take() (T, bool) {
+	// checked first, an empty PopFront makes an error.
+	if br.rb.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	v, _ := br.rb.PopFront()
+	wakeCh(&br.notFull)
+	return v, true
+}
+
+// Take takes the oldest value, waiting until there is one or ctx is done.
+// Like a channel, values put before Close can still be taken, then it
+// returns ErrRingClosed.
+func (br *BlockingRing[T]) Take(ctx context.Context) (T, error) {
+	br.mu.Lock()
+	for {
+		if v, ok := br.take(); ok {
+			br.mu.Unlock()
+			return v, nil
+		}
+		if br.closed {
+			br.mu.Unlock()
+			var zero T
+			return zero, ErrRingClosed
+		}
+		ch := waitCh(&br.notEmpty)
+		br.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		br.mu.Lock()
+	}
+}
+
+// TryTake takes the oldest value without waiting, it returns false if the
+// ring is empty.
+func (br *BlockingRing[T]) TryTake() (T, bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.take()
+}
+
+// Close closes the ring, Put fails and Take fails once the ring is empty.
+// Waiters are woken up.   Closing a closed ring does nothing.
+func (br *BlockingRing[T]) Close() {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.closed = true
+	wakeCh(&br.notEmpty)
+	wakeCh(&br.notFull)
+}
+
+// Len returns the number of values in the ring.
+func (br *BlockingRing[T]) Len() int {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.rb.Len()
+}
+
+// Cap returns the size of the ring.
+func (br *BlockingRing[T]) Cap() int {
+	return br.size
+}
+
+// Dropped returns the number of values dropped or overwritten when the
+// ring was full.
+func (br *BlockingRing[T]) Dropped() uint64 {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.dropped
+}
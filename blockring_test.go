@@ -0,0 +1,113 @@
+package gcl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockingRing(t *testing.T) {
+	const (
+		thCnt   = 4
+		loopCnt = 1000
+	)
+	br := NewBlockingRing[int](8, FullBlock)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < thCnt; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				if err := br.Put(ctx, i*loopCnt+j); err != nil {
+					t.Errorf("put: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		br.Close()
+	}()
+
+	// each producer's values come in order, none lost.
+	last := make([]int, thCnt)
+	for i := range last {
+		last[i] = -1
+	}
+	cnt := 0
+	for {
+		v, err := br.Take(ctx)
+		if errors.Is(err, ErrRingClosed) {
+			break
+		}
+		if p := v / loopCnt; v%loopCnt <= last[p] {
+			t.Fatalf("value %d after %d", v, last[p])
+		} else {
+			last[p] = v % loopCnt
+		}
+		cnt++
+	}
+	if cnt != thCnt*loopCnt {
+		t.Errorf("took %d values", cnt)
+	}
+	if br.Put(ctx, 1) != ErrRingClosed || br.TryPut(1) {
+		t.Errorf("put into a closed ring")
+	}
+}
+
+func TestBlockingRingWait(t *testing.T) {
+	br := NewBlockingRing[int](1, FullBlock)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := br.Take(ctx); err != context.DeadlineExceeded {
+		t.Errorf("take from empty ring got %v", err)
+	}
+	if !br.TryPut(1) || br.TryPut(2) {
+		t.Errorf("try put into a ring of 1")
+	}
+	if err := br.Put(ctx, 2); err != context.DeadlineExceeded {
+		t.Errorf("put into full ring got %v", err)
+	}
+
+	// a blocked taker is woken by Close.
+	br.TryTake()
+	done := make(chan error)
+	go func() {
+		_, err := br.Take(context.Background())
+		done <- err
+	}()
+	time.Sleep(time.Millisecond)
+	br.Close()
+	if err := <-done; err != ErrRingClosed {
+		t.Errorf("blocked take got %v", err)
+	}
+}
+
+func TestBlockingRingPolicy(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		policy FullPolicy
+		want   []int
+	}{
+		{FullDropNewest, []int{0, 1, 2}},
+		{FullOverwrite, []int{2, 3, 4}},
+	} {
+		br := NewBlockingRing[int](3, tc.policy)
+		for i := 0; i < 5; i++ {
+			br.Put(ctx, i)
+		}
+		var got []int
+		for v, ok := br.TryTake(); ok; v, ok = br.TryTake() {
+			got = append(got, v)
+		}
+		check(t, tc.want, got)
+		if br.Dropped() != 2 || br.Len() != 0 || br.Cap() != 3 {
+			t.Errorf("policy %d dropped %d", tc.policy, br.Dropped())
+		}
+	}
+}
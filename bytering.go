@@ -0,0 +1,281 @@
+package gcl
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+)
+
+//
+// ByteRing is a byte stream buffer in a circular buffer, bytes are moved
+// with at most two copies, one per segment of the buffer.
+//
+// Readers (Read, Peek, Discard, WriteTo) are serialized by rmu and own the
+// read position, writers (Write, ReadFrom) are serialized by wmu and own
+// the free space, so a reader and a writer copy at the same time, and
+// ReadFrom and WriteTo call their reader and writer without holding mu.
+// mu guards the position and length shared between the two sides.
+//
+// A blocking ring waits for data or room, a non blocking one returns
+// what it can, like bytes.Buffer.
+//
+
+// ErrRingFull is returned by a write to a full non blocking ring, or a
+// Peek of more than the ring holds.
+var ErrRingFull = errors.New("ring buffer full")
+
+type ByteRing struct {
+	rmu, wmu sync.Mutex
+
+	mu sync.Mutex
+	// read position, and bytes held.
+	r, n   int
+	block  bool
+	closed bool
+	// channels of waiters, nil if there are none.
+	notEmpty, notFull chan struct{}
+
+	buf []byte
+	// bytes of a Peek across the end of buf.
+	peek []byte
+}
+
+// NewByteRing creates a non blocking ring of size bytes.
+func NewByteRing(size int) *ByteRing {
+	MustCheck(size > 0, "ring buffer size must be positive")
+	return &ByteRing{buf: make([]byte, size)}
+}
+
+// SetBlocking turns blocking on or off.   When it is on, reads wait for
+// data and writes wait for room, until the ring is closed.
+func (br *ByteRing) SetBlocking(on bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.block = on
+	wakeCh(&br.notEmpty)
+	wakeCh(&br.notFull)
+}
+
+// segs returns n bytes of buf from off, in one or two segments.
+func (br *ByteRing) segs(off, n int) (a, b []byte) {
+	size := len(br.buf)
+	if off >= size {
+		off -= size
+	}
+	if off+n <= size {
+		return br.buf[off : off+n], nil
+	}
+	return br.buf[off:], br.buf[:off+n-size]
+}
+
+// waitData waits, if blocking, until n bytes are held or the ring is
+// closed, and returns the bytes held.   br.rmu must be held.
+func (br *ByteRing) waitData(n int) int {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	for br.block && br.n < n && !br.closed {
+		ch := waitCh(&br.notEmpty)
+		br.mu.Unlock()
+		<-ch
+		br.mu.Lock()
+	}
+	return br.n
+}
+
+// waitRoom waits, if blocking, until there is room or the ring is closed,
+// and returns where to write and the room.   br.wmu must be held.
+func (br *ByteRing) waitRoom() (int, int, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	for br.block && br.n == len(br.buf) && !br.closed {
+		ch := waitCh(&br.notFull)
+		br.mu.Unlock()
+		<-ch
+		br.mu.Lock()
+	}
+	switch {
+	case br.closed:
+		return 0, 0, ErrRingClosed
+	case br.n == len(br.buf):
+		return 0, 0, ErrRingFull
+	}
+	return br.r + br.n, len(br.buf) - br.n, nil
+}
+
+// consumed moves the read position past m bytes.
+func (br *ByteRing) consumed(m int) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if br.r += m; br.r >= len(br.buf) {
+		br.r -= len(br.buf)
+	}
+	br.n -= m
+	wakeCh(&br.notFull)
+}
+
+// produced adds m bytes written after the held ones.
+func (br *ByteRing) produced(m int) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.n += m
+	wakeCh(&br.notEmpty)
+}
+
+// Read reads up to len(p) bytes.   It returns io.EOF if the ring is empty
+// and closed, or empty and not blocking.
+func (br *ByteRing) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	br.rmu.Lock()
+	defer br.rmu.Unlock()
+	held := br.waitData(1)
+	if held == 0 {
+		return 0, io.EOF
+	}
+	a, b := br.segs(br.r, min(held, len(p)))
+	n := copy(p, a)
+	n += copy(p[n:], b)
+	br.consumed(n)
+	return n, nil
+}
+
+// Write writes p, waiting for room if blocking.   A non blocking ring
+// writes what fits and returns ErrRingFull if that is not all of p.
+func (br *ByteRing) Write(p []byte) (int, error) {
+	br.wmu.Lock()
+	defer br.wmu.Unlock()
+	done := 0
+	for done < len(p) {
+		w, room, err := br.waitRoom()
+		if err != nil {
+			return done, err
+		}
+		a, b := br.segs(w, min(room, len(p)-done))
+		m := copy(a, p[done:])
+		m += copy(b, p[done+m:])
+		br.produced(m)
+		done += m
+	}
+	return done, nil
+}
+
+// ReadFrom reads from r into the ring until r returns io.EOF, which is
+// not returned, or the ring is full and not blocking.
+func (br *ByteRing) ReadFrom(r io.Reader) (int64, error) {
+	br.wmu.Lock()
+	defer br.wmu.Unlock()
+	var total int64
+	for {
+		w, room, err := br.waitRoom()
+		if err != nil {
+			return total, err
+		}
+		a, _ := br.segs(w, room)
+		m, err := r.Read(a)
+		if m > 0 {
+			br.produced(m)
+			total += int64(m)
+		}
+		if err == io.EOF {
+			return total, nil
+		} else if err != nil {
+			return total, err
+		}
+	}
+}
+
+// WriteTo writes the ring's bytes to w until the ring is empty and, if
+// blocking, closed.
+func (br *ByteRing) WriteTo(w io.Writer) (int64, error) {
+	br.rmu.Lock()
+	defer br.rmu.Unlock()
+	var total int64
+	for {
+		held := br.waitData(1)
+		if held == 0 {
+			return total, nil
+		}
+		a, _ := br.segs(br.r, held)
+		m, err := w.Write(a)
+		if m > 0 {
+			br.consumed(m)
+			total += int64(m)
+		}
+		if err != nil {
+			return total, err
+		}
+		if m < len(a) {
+			return total, io.ErrShortWrite
+		}
+	}
+}
+
+// Peek returns the next n bytes without reading them, waiting for them if
+// blocking.   If fewer are held, it returns them with io.EOF.   The bytes
+// are valid until the next read.
+func (br *ByteRing) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, bufio.ErrNegativeCount
+	}
+	if n > len(br.buf) {
+		return nil, ErrRingFull
+	}
+	br.rmu.Lock()
+	defer br.rmu.Unlock()
+	m := min(n, br.waitData(n))
+	a, b := br.segs(br.r, m)
+	if len(b) > 0 {
+		br.peek = append(append(br.peek[:0], a...), b...)
+		a = br.peek
+	}
+	if m < n {
+		return a, io.EOF
+	}
+	return a, nil
+}
+
+// Discard skips the next n bytes, waiting for them if blocking.   If
+// fewer are held, it skips them and returns io.EOF.
+func (br *ByteRing) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, bufio.ErrNegativeCount
+	}
+	br.rmu.Lock()
+	defer br.rmu.Unlock()
+	done := 0
+	for done < n {
+		held := br.waitData(1)
+		if held == 0 {
+			return done, io.EOF
+		}
+		m := min(n-done, held)
+		br.consumed(m)
+		done += m
+	}
+	return done, nil
+}
+
+// Close closes the ring, writes fail and reads return io.EOF once the
+// ring is empty.   Waiters are woken up.
+func (br *ByteRing) Close() error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.closed = true
+	wakeCh(&br.notEmpty)
+	wakeCh(&br.notFull)
+	return nil
+}
+
+// Len returns the number of bytes held.
+func (br *ByteRing) Len() int {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.n
+}
+
+// Cap returns the size of the ring.
+func (br *ByteRing) Cap() int {
+	return len(br.buf)
+}
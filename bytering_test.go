@@ -0,0 +1,145 @@
+package gcl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+func TestByteRing(t *testing.T) {
+	br := NewByteRing(8)
+	if n, err := br.Read(make([]byte, 4)); n != 0 || err != io.EOF {
+		t.Errorf("read empty ring got %d %v", n, err)
+	}
+	if n, err := br.Write([]byte("0123456789")); n != 8 || err != ErrRingFull {
+		t.Errorf("write full ring got %d %v", n, err)
+	}
+
+	// move the read position so data wraps around.
+	p := make([]byte, 5)
+	if n, _ := br.Read(p); n != 5 || string(p) != "01234" {
+		t.Errorf("read got %q", p[:n])
+	}
+	br.Write([]byte("abcd"))
+	if br.Len() != 7 || br.Cap() != 8 {
+		t.Errorf("len %d cap %d", br.Len(), br.Cap())
+	}
+	if b, err := br.Peek(6); err != nil || string(b) != "567abc" {
+		t.Errorf("peek got %q %v", b, err)
+	}
+	if b, err := br.Peek(9); b != nil || err != ErrRingFull {
+		t.Errorf("peek past size got %q %v", b, err)
+	}
+	if b, err := br.Peek(-1); b != nil || err != bufio.ErrNegativeCount {
+		t.Errorf("negative peek got %q %v", b, err)
+	}
+	if n, err := br.Discard(-1); n != 0 || err != bufio.ErrNegativeCount {
+		t.Errorf("negative discard got %d %v", n, err)
+	}
+	if n, err := br.Discard(2); n != 2 || err != nil {
+		t.Errorf("discard got %d %v", n, err)
+	}
+	if b, err := br.Peek(8); err != io.EOF || string(b) != "7abcd" {
+		t.Errorf("short peek got %q %v", b, err)
+	}
+
+	var out bytes.Buffer
+	if n, err := br.WriteTo(&out); n != 5 || err != nil || out.String() != "7abcd" {
+		t.Errorf("write to got %d %v %q", n, err, out.String())
+	}
+	if n, err := br.ReadFrom(bytes.NewReader([]byte("xyz"))); n != 3 || err != nil {
+		t.Errorf("read from got %d %v", n, err)
+	}
+	if n, err := br.Discard(4); n != 3 || err != io.EOF {
+		t.Errorf("short discard got %d %v", n, err)
+	}
+
+	br.Close()
+	if _, err := br.Write([]byte("x")); err != ErrRingClosed {
+		t.Errorf("write closed ring got %v", err)
+	}
+}
+
+// TestByteRingStream passes a stream through a small blocking ring, with
+// the reader mixing its ways to read.
+func TestByteRingStream(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 100000)
+	r.Read(data)
+
+	for _, viaReadFrom := range []bool{false, true} {
+		br := NewByteRing(13)
+		br.SetBlocking(true)
+		go func() {
+			defer br.Close()
+			if viaReadFrom {
+				br.ReadFrom(iotest.HalfReader(bytes.NewReader(data)))
+				return
+			}
+			for rest := data; len(rest) > 0; {
+				n := min(len(rest), 1+r.Intn(30))
+				br.Write(rest[:n])
+				rest = rest[n:]
+			}
+		}()
+
+		var got []byte
+		p := make([]byte, 20)
+	loop:
+		for i := 0; ; i++ {
+			switch i % 4 {
+			case 0:
+				n, err := br.Read(p)
+				got = append(got, p[:n]...)
+				if err == io.EOF {
+					break loop
+				}
+			case 1:
+				b, _ := br.Peek(7)
+				got = append(got, b...)
+				br.Discard(len(b))
+			default:
+				b, _ := br.Peek(1)
+				got = append(got, b...)
+				br.Discard(len(b))
+			}
+			if i == 10000 {
+				var out bytes.Buffer
+				br.WriteTo(&out)
+				got = append(got, out.Bytes()...)
+			}
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("read from %v: got %d bytes, want %d", viaReadFrom, len(got), len(data))
+		}
+	}
+}
+
+func BenchmarkByteRing(b *testing.B) {
+	const chunk = 256
+	src := make([]byte, chunk)
+	dst := make([]byte, chunk)
+	b.Run("byte-ring", func(b *testing.B) {
+		br := NewByteRing(4096)
+		b.SetBytes(chunk)
+		for i := 0; i < b.N; i++ {
+			br.Write(src)
+			br.Read(dst)
+		}
+	})
+	b.Run("ring-buffer", func(b *testing.B) {
+		rb := NewRingBuffer[byte](4096)
+		b.SetBytes(chunk)
+		for i := 0; i < b.N; i++ {
+			for _, c := range src {
+				rb.Push(c)
+			}
+			for j := range dst {
+				dst[j], _ = rb.PopFront()
+			}
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package gcl
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+//
+// ConcurrentMap is a hash map striped over shards, each a plain go map
+// guarded by its own RWMutex.   Point operations lock one shard, so
+// writers to different shards never contend.   Shards are padded to a
+// cache line so that their locks do not share one.
+//
+
+const cacheLineSize = 64
+
+type cmShard[K comparable, V any] struct {
+	sync.RWMutex
+	m   map[K]V
+	cnt atomic.Int64
+	_   [cacheLineSize]byte
+}
+
+type ConcurrentMap[K comparable, V any] struct {
+	shards []cmShard[K, V]
+	mask   uint64
+	hash   func(K) uint64
+}
+
+// NewConcurrentMap creates a map that spreads keys over shards by hash.
+// shards is rounded up to a power of 2, 0 picks 4 shards per CPU.
+func NewConcurrentMap[K comparable, V any](hash func(K) uint64, shards int) *ConcurrentMap[K, V] {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+
+	cm := &ConcurrentMap[K, V]{
+		shards: make([]cmShard[K, V], n),
+		mask:   uint64(n - 1),
+		hash:   hash,
+	}
+	for i := range cm.shards {
+		cm.shards[i].m = make(map[K]V)
+	}
+	return cm
+}
+
+func (cm *ConcurrentMap[K, V]) shard(k K) *cmShard[K, V] {
+	return &cm.shards[cm.hash(k)&cm.mask]
+}
+
+// Load returns the value of k, and if k is in the map.
+func (cm *ConcurrentMap[K, V]) Load(k K) (V, bool) {
+	s := cm.shard(k)
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.m[k]
+	return v, ok
+}
+
+// Store sets the value of k.
+func (cm *ConcurrentMap[K, V]) Store(k K, v V) {
+	s := cm.shard(k)
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.m[k]; !ok {
+		s.cnt.Add(1)
+	}
+	s.m[k] = v
+}
+
+// LoadOrStore returns the existing value of k if there is one, otherwise
+// it stores and returns v.  loaded is true if the value was loaded.
+func (cm *ConcurrentMap[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
+	s := cm.shard(k)
+	s.Lock()
+	defer s.Unlock()
+	if old, ok := s.m[k]; ok {
+		return old, true
+	}
+	s.m[k] = v
+	s.cnt.Add(1)
+	return v, false
+}
+
+// LoadAndDelete deletes k, returning its value if there was one.
+func (cm *ConcurrentMap[K, V]) LoadAndDelete(k K) (V, bool) {
+	s := cm.shard(k)
+	s.Lock()
+	defer s.Unlock()
+	v, ok := s.m[k]
+	if ok {
+		delete(s.m, k)
+		s.cnt.Add(-1)
+	}
+	return v, ok
+}
+
+// Delete deletes k.
+func (cm *ConcurrentMap[K, V]) Delete(k K) {
+	cm.LoadAndDelete(k)
+}
+
+// Compute atomically replaces the value of k with the result of fn, which
+// gets the old value and if k was in the map.   If fn returns del, k is
+// deleted.   fn runs with the shard locked, it must not use the map.
+func (cm *ConcurrentMap[K, V]) Compute(k K, fn func(old V, loaded bool) (nv V, del bool)) (V, bool) {
+	s := cm.shard(k)
+	s.Lock()
+	defer s.Unlock()
+	old, loaded := s.m[k]
+	nv, del := fn(old, loaded)
+	switch {
+	case del && loaded:
+		delete(s.m, k)
+		s.cnt.Add(-1)
+	case del:
+	case !loaded:
+		s.m[k] = nv
+		s.cnt.Add(1)
+	default:
+		s.m[k] = nv
+	}
+	if del {
+		var zero V
+		return zero, false
+	}
+	return nv, true
+}
+
+// Range calls fn for each key value pair until fn returns false.   Each
+// shard is copied under its read lock and fn runs unlocked, so fn may use
+// the map.   Like sync.Map.Range, it is not a consistent snapshot.
+func (cm *ConcurrentMap[K, V]) Range(fn func(k K, v V) bool) {
+	type kv struct {
+		k K
+		v V
+	}
+	var buf []kv
+	for i := range cm.shards {
+		s := &cm.shards[i]
+		s.RLock()
+		buf = buf[:0]
+		for k, v := range s.m {
+			buf = append(buf, kv{k, v})
+		}
+		s.RUnlock()
+
+		for _, e := range buf {
+			if !fn(e.k, e.v) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of keys, without locking.   It is exact when
+// there are no concurrent writers.
+func (cm *ConcurrentMap[K, V]) Len() int {
+	var n int64
+	for i := range cm.shards {
+		n += cm.shards[i].cnt.Load()
+	}
+	return int(n)
+}
+
+var hashSeed = maphash.MakeSeed()
+
+// StringHash hashes a string for ConcurrentMap.
+func StringHash(s string) uint64 {
+	return maphash.String(hashSeed, s)
+}
+
+// IntHash hashes an integer for ConcurrentMap, with the splitmix64
+// finalizer, so that sequential keys spread over all shards.
+func IntHash[I ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr](i I) uint64 {
+	x := uint64(i)
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// UUIDHash hashes a uuid.UUID for ConcurrentMap.
+func UUIDHash(u uuid.UUID) uint64 {
+	return maphash.Bytes(hashSeed, u[:])
+}
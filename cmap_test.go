@@ -0,0 +1,137 @@
+package gcl
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentMap(t *testing.T) {
+	cm := NewConcurrentMap[string, int](StringHash, 0)
+	cm.Store("a", 1)
+	if v, loaded := cm.LoadOrStore("a", 2); !loaded || v != 1 {
+		t.Errorf("LoadOrStore existing got %d, %v", v, loaded)
+	}
+	if v, loaded := cm.LoadOrStore("b", 2); loaded || v != 2 {
+		t.Errorf("LoadOrStore new got %d, %v", v, loaded)
+	}
+	cm.Compute("a", func(old int, loaded bool) (int, bool) { return old + 10, false })
+	if v, ok := cm.Load("a"); !ok || v != 11 {
+		t.Errorf("Compute got %d, %v", v, ok)
+	}
+	cm.Compute("b", func(old int, loaded bool) (int, bool) { return 0, true })
+	if _, ok := cm.Load("b"); ok || cm.Len() != 1 {
+		t.Errorf("Compute should delete b, len %d", cm.Len())
+	}
+	cm.Delete("a")
+	cm.Delete("a")
+	if cm.Len() != 0 {
+		t.Errorf("len %d after delete", cm.Len())
+	}
+}
+
+func TestConcurrentMapCounter(t *testing.T) {
+	const (
+		thCnt   = 8
+		loopCnt = 2000
+		kRange  = 100
+	)
+	cm := NewConcurrentMap[int, int](IntHash[int], 4)
+	var wg sync.WaitGroup
+	for i := 0; i < thCnt; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				cm.Compute(j%kRange, func(old int, loaded bool) (int, bool) {
+					return old + 1, false
+				})
+				if j%7 == 0 {
+					cm.Range(func(k, v int) bool { return k < kRange/2 })
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if cm.Len() != kRange {
+		t.Errorf("len %d, want %d", cm.Len(), kRange)
+	}
+	total := 0
+	cm.Range(func(k, v int) bool {
+		total += v
+		return true
+	})
+	if total != thCnt*loopCnt {
+		t.Errorf("lost updates, total %d, want %d", total, thCnt*loopCnt)
+	}
+}
+
+// mapAPI is the common api of the maps in the benchmark.
+type mapAPI interface {
+	Load(k int) (int, bool)
+	Store(k, v int)
+}
+
+type syncMap struct{ m sync.Map }
+
+func (s *syncMap) Load(k int) (int, bool) {
+	v, ok := s.m.Load(k)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+func (s *syncMap) Store(k, v int) { s.m.Store(k, v) }
+
+type mutexMap struct {
+	sync.RWMutex
+	m map[int]int
+}
+
+func (s *mutexMap) Load(k int) (int, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.m[k]
+	return v, ok
+}
+func (s *mutexMap) Store(k, v int) {
+	s.Lock()
+	defer s.Unlock()
+	s.m[k] = v
+}
+
+func BenchmarkConcurrentMap(b *testing.B) {
+	const kRange = 100000
+	maps := []struct {
+		name string
+		mk   func() mapAPI
+	}{
+		{"cmap", func() mapAPI { return NewConcurrentMap[int, int](IntHash[int], 0) }},
+		{"syncmap", func() mapAPI { return &syncMap{} }},
+		{"mutexmap", func() mapAPI { return &mutexMap{m: make(map[int]int)} }},
+	}
+	for _, writePct := range []int{1, 10, 50, 100} {
+		for _, m := range maps {
+			b.Run(fmt.Sprintf("%s-write%d", m.name, writePct), func(b *testing.B) {
+				cm := m.mk()
+				for i := 0; i < kRange; i++ {
+					cm.Store(i, i)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					r := rand.New(rand.NewSource(rand.Int63()))
+					for pb.Next() {
+						k := r.Intn(kRange)
+						if r.Intn(100) < writePct {
+							cm.Store(k, k)
+						} else {
+							cm.Load(k)
+						}
+					}
+				})
+			})
+		}
+	}
+}
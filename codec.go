@@ -0,0 +1,375 @@
+package gcl
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+//
+// Binary format of ordered containers.
+//
+//	header:  magic "GCLS", version byte
+//	entry:   tag 1, uvarint key length, key, uvarint value length, value
+//	end:     tag 0, uvarint entry count, crc32c of everything before it
+//
+// Keys are written in ascending order, so loading links nodes at the end
+// of the list in O(n), without searching.
+//
+
+const (
+	sortedMagic   = "GCLS"
+	sortedVersion = 1
+	tagEntry      = 1
+	tagEnd        = 0
+	// sanity limit of a key or value, against corrupted lengths.
+	maxFieldLen = 1 << 30
+	// a field is read in chunks, so a corrupted length does not allocate
+	// more than the stream holds.
+	fieldChunk = 64 << 10
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Codec encodes and decodes values of type T.
+type Codec[T any] interface {
+	// Append appends the encoding of v to buf.
+	Append(buf []byte, v T) ([]byte, error)
+	// Decode decodes a value from exactly data.
+	Decode(data []byte) (T, error)
+}
+
+// IntCodec encodes integers as zigzag varints.
+type IntCodec[I ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64] struct{}
+
+func (IntCodec[I]) Append(buf []byte, v I) ([]byte, error) {
+	return binary.AppendVarint(buf, int64(v)), nil
+}
+
+func (IntCodec[I]) Decode(data []byte) (I, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 || n != len(data) {
+		return 0, fmt.Errorf("bad varint")
+	}
+	return I(v), nil
+}
+
+// StringCodec encodes strings as is.
+type StringCodec struct{}
+
+func (StringCodec) Append(buf []byte, v string) ([]byte, error) {
+	return append(buf, v...), nil
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// BytesCodec encodes byte slices as is.
+type BytesCodec struct{}
+
+func (BytesCodec) Append(buf []byte, v []byte) ([]byte, error) {
+	return append(buf, v...), nil
+}
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return append([]byte(nil), data...), nil
+}
+
+// UUIDCodec encodes a uuid.UUID as its 16 bytes.
+type UUIDCodec struct{}
+
+func (UUIDCodec) Append(buf []byte, v uuid.UUID) ([]byte, error) {
+	return append(buf, v[:]...), nil
+}
+
+func (UUIDCodec) Decode(data []byte) (uuid.UUID, error) {
+	return uuid.FromBytes(data)
+}
+
+// BinaryCodec encodes types implementing encoding.BinaryMarshaler, where
+// *T implements encoding.BinaryUnmarshaler.
+type BinaryCodec[T any, PT interface {
+	*T
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}] struct{}
+
+func (BinaryCodec[T, PT]) Append(buf []byte, v T) ([]byte, error) {
+	data, err := PT(&v).MarshalBinary()
+	if err != nil {
+		return buf, err
+	}
+	return append(buf, data...), nil
+}
+
+func (BinaryCodec[T, PT]) Decode(data []byte) (T, error) {
+	var v T
+	err := PT(&v).UnmarshalBinary(data)
+	return v, err
+}
+
+// EncodeSorted writes key value pairs, in ascending key order, to w.  It
+// returns the number of bytes written.
+func EncodeSorted[K any, V any](w io.Writer, seq iter.Seq2[K, V], kc Codec[K], vc Codec[V]) (int64, error) {
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	crc := crc32.New(crcTable)
+	out := io.MultiWriter(bw, crc)
+
+	buf := append([]byte(sortedMagic), sortedVersion)
+	_, err := out.Write(buf)
+	if err != nil {
+		return cw.n, err
+	}
+
+	var cnt uint64
+	for k, v := range seq {
+		buf = append(buf[:0], tagEntry)
+		if buf, err = appendField(buf, k, kc); err != nil {
+			return cw.n, err
+		}
+		if buf, err = appendField(buf, v, vc); err != nil {
+			return cw.n, err
+		}
+		if _, err = out.Write(buf); err != nil {
+			return cw.n, err
+		}
+		cnt++
+	}
+
+	buf = append(buf[:0], tagEnd)
+	buf = binary.AppendUvarint(buf, cnt)
+	if _, err = out.Write(buf); err != nil {
+		return cw.n, err
+	}
+	if _, err = bw.Write(crc.Sum(nil)); err != nil {
+		return cw.n, err
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+var varintPad [binary.MaxVarintLen64]byte
+
+// appendField appends the length prefixed encoding of v.
+func appendField[T any](buf []byte, v T, c Codec[T]) ([]byte, error) {
+	// reserve the longest uvarint, then move the data down.
+	start := len(buf)
+	buf = append(buf, varintPad[:]...)
+	buf, err := c.Append(buf, v)
+	if err != nil {
+		return buf, err
+	}
+	data := buf[start+binary.MaxVarintLen64:]
+	n := binary.PutUvarint(buf[start:], uint64(len(data)))
+	copy(buf[start+n:], data)
+	return buf[:start+n+len(data)], nil
+}
+
+// DecodeSorted reads a stream written by EncodeSorted and calls fn for
+// each key value pair in order.   It may read past the end of the stream.
+func DecodeSorted[K any, V any](r io.Reader, kc Codec[K], vc Codec[V], fn func(k K, v V) error) (int64, error) {
+	cr := &crcReader{r: bufio.NewReader(r)}
+	hdr := make([]byte, len(sortedMagic)+1)
+	if _, err := io.ReadFull(cr, hdr); err != nil {
+		return cr.n, err
+	}
+	if string(hdr[:len(sortedMagic)]) != sortedMagic {
+		return cr.n, fmt.Errorf("bad magic %q", hdr[:len(sortedMagic)])
+	}
+	if hdr[len(sortedMagic)] != sortedVersion {
+		return cr.n, fmt.Errorf("unsupported version %d", hdr[len(sortedMagic)])
+	}
+
+	var cnt uint64
+	var buf []byte
+	for {
+		tag, err := cr.ReadByte()
+		if err != nil {
+			return cr.n, noEOF(err)
+		}
+		if tag == tagEnd {
+			break
+		}
+		if tag != tagEntry {
+			return cr.n, fmt.Errorf("bad tag %d", tag)
+		}
+
+		var k K
+		var v V
+		if buf, err = readField(cr, buf); err == nil {
+			k, err = kc.Decode(buf)
+		}
+		if err == nil {
+			buf, err = readField(cr, buf)
+		}
+		if err == nil {
+			v, err = vc.Decode(buf)
+		}
+		if err == nil {
+			err = fn(k, v)
+		}
+		if err != nil {
+			return cr.n, noEOF(err)
+		}
+		cnt++
+	}
+
+	n, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return cr.n, noEOF(err)
+	}
+	if n != cnt {
+		return cr.n, fmt.Errorf("entry count %d, want %d", cnt, n)
+	}
+	sum := cr.crc
+	var want [4]byte
+	if _, err := io.ReadFull(cr, want[:]); err != nil {
+		return cr.n, noEOF(err)
+	}
+	if binary.BigEndian.Uint32(want[:]) != sum {
+		return cr.n, fmt.Errorf("checksum mismatch")
+	}
+	return cr.n, nil
+}
+
+func readField(cr *crcReader, buf []byte) ([]byte, error) {
+	n, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return buf, err
+	}
+	if n > maxFieldLen {
+		return buf, fmt.Errorf("field length %d too large", n)
+	}
+	buf = buf[:0]
+	for uint64(len(buf)) < n {
+		m := min(int(n)-len(buf), fieldChunk)
+		buf = slices.Grow(buf, m)
+		got, err := io.ReadFull(cr, buf[len(buf):len(buf)+m])
+		buf = buf[:len(buf)+got]
+		if err != nil {
+			return buf, err
+		}
+	}
+	return buf, nil
+}
+
+// a truncated stream is an error, not the end of it.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// crcReader counts and checksums what is read.
+type crcReader struct {
+	r   *bufio.Reader
+	crc uint32
+	n   int64
+}
+
+func (cr *crcReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.crc = crc32.Update(cr.crc, crcTable, p[:n])
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *crcReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.crc = crc32.Update(cr.crc, crcTable, []byte{b})
+		cr.n++
+	}
+	return b, err
+}
+
+type sortedPair[K any, V any] struct {
+	k K
+	v V
+}
+
+// decodeStaged reads a whole stream into pairs, checking that keys are
+// strictly ascending by cmp.   The checksum and count are only at the
+// end, so nothing read may be used before the stream is.
+func decodeStaged[K any, V any](r io.Reader, kc Codec[K], vc Codec[V], cmp func(a, b K) int) ([]sortedPair[K, V], int64, error) {
+	var pairs []sortedPair[K, V]
+	n, err := DecodeSorted(r, kc, vc, func(k K, v V) error {
+		if len(pairs) > 0 && cmp(pairs[len(pairs)-1].k, k) >= 0 {
+			return fmt.Errorf("input is not sorted")
+		}
+		pairs = append(pairs, sortedPair[K, V]{k, v})
+		return nil
+	})
+	return pairs, n, err
+}
+
+func pairSeq[K any, V any](pairs []sortedPair[K, V]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, p := range pairs {
+			if !yield(p.k, p.v) {
+				return
+			}
+		}
+	}
+}
+
+// SaveTo writes the list to w in key order, see EncodeSorted.   Keys
+// added or removed concurrently may or may not be written, save a
+// MVSnapshot with EncodeSorted for a point-in-time copy.
+func (lsl *Skiplist[K, V]) SaveTo(w io.Writer, kc Codec[K], vc Codec[V]) (int64, error) {
+	return EncodeSorted(w, lsl.All(), kc, vc)
+}
+
+// LoadFrom reads a list written by SaveTo into lsl, which must be empty
+// or only hold keys less than the ones read.   The stream is read and
+// checked before any node is linked, so on error lsl is unchanged.   It
+// links nodes directly in O(n) and must not run concurrently with other
+// writers.
+func (lsl *Skiplist[K, V]) LoadFrom(r io.Reader, kc Codec[K], vc Codec[V]) (int64, error) {
+	pairs, n, err := decodeStaged(r, kc, vc, lsl.cmp)
+	if err != nil {
+		return n, err
+	}
+	// sorted, so only the first key can fail, before anything is linked.
+	return n, lsl.appendSorted(pairSeq(pairs))
+}
+
+// SaveTo writes the list to w in key order, see EncodeSorted.
+func (l *LazyList[K, V]) SaveTo(w io.Writer, kc Codec[K], vc Codec[V]) (int64, error) {
+	return EncodeSorted(w, l.All(), kc, vc)
+}
+
+// LoadFrom reads a list written by SaveTo into l, which must be empty or
+// only hold keys less than the ones read.   The stream is read and
+// checked before any node is linked, so on error l is unchanged.   It
+// links nodes directly in O(n) and must not run concurrently with other
+// writers.
+func (l *LazyList[K, V]) LoadFrom(r io.Reader, kc Codec[K], vc Codec[V]) (int64, error) {
+	pairs, n, err := decodeStaged(r, kc, vc, l.cmp)
+	if err != nil {
+		return n, err
+	}
+	return n, l.appendSorted(pairSeq(pairs))
+}
@@ -0,0 +1,156 @@
+package gcl
+
+import (
+	"bytes"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSkiplistSaveLoad(t *testing.T) {
+	list := NewOrderedSkipList[int64, string]()
+	for i := int64(-500); i < 500; i += 3 {
+		list.Add(i, strconv.FormatInt(i, 10))
+	}
+
+	var buf bytes.Buffer
+	n, err := list.SaveTo(&buf, IntCodec[int64]{}, StringCodec{})
+	if err != nil || n != int64(buf.Len()) {
+		t.Fatalf("save wrote %d of %d bytes, %v", n, buf.Len(), err)
+	}
+	data := buf.Bytes()
+
+	loaded := NewOrderedSkipList[int64, string]()
+	if _, err := loaded.LoadFrom(bytes.NewReader(data), IntCodec[int64]{}, StringCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	var cnt int
+	for k, v := range loaded.All() {
+		if v != strconv.FormatInt(k, 10) {
+			t.Fatalf("key %d has value %s", k, v)
+		}
+		cnt++
+	}
+	if cnt != 334 {
+		t.Errorf("loaded %d keys, want 334", cnt)
+	}
+	// loaded list is a valid skiplist.
+	if !loaded.Add(2, "2") || !loaded.Remove(-500) {
+		t.Errorf("loaded list cannot be updated")
+	}
+
+	// corruption, truncation, a newer version and keys out of order are
+	// all errors, and leave the list as it was.
+	swapped := NewOrderedSkipList[int64, string]()
+	swapped.Add(2, "2")
+	swapped.Add(1, "1")
+	var sbuf bytes.Buffer
+	EncodeSorted(&sbuf, func(yield func(int64, string) bool) {
+		_ = yield(0, "0") && yield(2, "2") && yield(1, "1")
+	}, IntCodec[int64]{}, StringCodec{})
+	for i, bad := range [][]byte{
+		append(append([]byte(nil), data[:20]...), append([]byte{data[20] ^ 1}, data[21:]...)...),
+		data[:len(data)-1],
+		data[:len(data)-5],
+		append([]byte("GCLS\x02"), data[5:]...),
+		sbuf.Bytes(),
+	} {
+		l := NewOrderedSkipList[int64, string]()
+		l.Add(-1000, "x")
+		if _, err := l.LoadFrom(bytes.NewReader(bad), IntCodec[int64]{}, StringCodec{}); err == nil {
+			t.Errorf("bad stream %d loaded", i)
+		}
+		checkSkiplistKeys(t, l, -1000)
+	}
+
+	// a huge field length in a short stream is not allocated.
+	huge := append([]byte("GCLS\x01\x01"), 0xff, 0xff, 0xff, 0xff, 0x03, 'x')
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	before := ms.TotalAlloc
+	if _, err := NewOrderedSkipList[int64, string]().LoadFrom(bytes.NewReader(huge), IntCodec[int64]{}, StringCodec{}); err == nil {
+		t.Errorf("huge field loaded")
+	}
+	runtime.ReadMemStats(&ms)
+	if ms.TotalAlloc-before > 1<<20 {
+		t.Errorf("huge field allocated %d bytes", ms.TotalAlloc-before)
+	}
+
+	// keys must be greater than the ones in the list.
+	unsorted := NewOrderedSkipList[int64, string]()
+	unsorted.Add(1000, "")
+	if _, err := unsorted.LoadFrom(bytes.NewReader(data), IntCodec[int64]{}, StringCodec{}); err == nil {
+		t.Errorf("loaded keys smaller than existing keys")
+	}
+	checkSkiplistKeys(t, unsorted, 1000)
+}
+
+func checkSkiplistKeys(t *testing.T, l *Skiplist[int64, string], want ...int64) {
+	t.Helper()
+	var keys []int64
+	for k := range l.All() {
+		keys = append(keys, k)
+	}
+	if !slices.Equal(keys, want) {
+		t.Errorf("list has %v, want %v", keys, want)
+	}
+}
+
+func TestLazyListSaveLoad(t *testing.T) {
+	list := NewUUIDLazyList[time.Time]()
+	now := time.Now().UTC()
+	for i := 0; i < 100; i++ {
+		list.Add(uuid.New(), now.Add(time.Duration(i)*time.Second))
+	}
+
+	var buf bytes.Buffer
+	if _, err := list.SaveTo(&buf, UUIDCodec{}, BinaryCodec[time.Time, *time.Time]{}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// a truncated stream loads nothing.
+	loaded := NewUUIDLazyList[time.Time]()
+	if _, err := loaded.LoadFrom(bytes.NewReader(data[:len(data)-5]), UUIDCodec{}, BinaryCodec[time.Time, *time.Time]{}); err == nil {
+		t.Errorf("truncated stream loaded")
+	}
+	if loaded.Len() != 0 {
+		t.Errorf("truncated stream left %d keys", loaded.Len())
+	}
+
+	if _, err := loaded.LoadFrom(bytes.NewReader(data), UUIDCodec{}, BinaryCodec[time.Time, *time.Time]{}); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range list.All() {
+		if lv, ok := loaded.Lookup(k); !ok || !lv.Equal(v) {
+			t.Fatalf("key %v got %v, want %v", k, lv, v)
+		}
+	}
+}
+
+func TestMVSnapshotEncode(t *testing.T) {
+	list := NewMVSkiplistFunc[string, []byte](func(a, b string) int { return bytes.Compare([]byte(a), []byte(b)) })
+	list.Put("a", []byte("1"))
+	snap := list.Snapshot()
+	defer snap.Close()
+	list.Put("b", []byte("2"))
+
+	var buf bytes.Buffer
+	if _, err := EncodeSorted(&buf, snap.All(), StringCodec{}, BytesCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	if _, err := DecodeSorted(&buf, StringCodec{}, BytesCodec{}, func(k string, v []byte) error {
+		keys = append(keys, k+string(v))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0] != "a1" {
+		t.Errorf("snapshot encoded %v", keys)
+	}
+}
@@ -0,0 +1,16 @@
+package gcl
+
+// cmpOf turns a less and eq pair into a three-way comparator.   Only the
+// constructors taking less and eq use it, it costs up to two calls per
+// comparison.
+func cmpOf[K any](less, eq func(a, b K) bool) func(a, b K) int {
+	return func(a, b K) int {
+		if less(a, b) {
+			return -1
+		}
+		if eq(a, b) {
+			return 0
+		}
+		return 1
+	}
+}
@@ -0,0 +1,130 @@
+package gcl
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// Epoch based reclamation.   Operations pin the current global epoch while
+// they may hold pointers to nodes.   A node unlinked and retired in epoch
+// e goes to the limbo bucket of e, and can be reused once the global
+// epoch reaches e+2: the epoch only advances from g to g+1 when no one is
+// pinned at g-1, so no one pinned at e or earlier is left, and anyone
+// pinned later started after the node was unlinked.
+//
+// Three epochs are live at most, so pins are counted per epoch mod 3 and
+// the limbo has three buckets.
+//
+// Every operation pins, so the pin counts are spread over slots, each on
+// its own cache line, and a pin picks a slot at random.   A pin is only
+// undone in its own slot, so a slot never counts less than the guards
+// held in it, and the sum over slots is never short.
+//
+
+type paddedCounter struct {
+	atomic.Int64
+	_ [cacheLineSize - 8]byte
+}
+
+// epochSlot counts the pins of each live epoch, mod 3.
+type epochSlot struct {
+	active [3]atomic.Int64
+	_      [cacheLineSize - 24]byte
+}
+
+type epoch struct {
+	global atomic.Uint64
+	// a power of 2 of them.
+	slots []epochSlot
+}
+
+func newEpoch() epoch {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) {
+		n *= 2
+	}
+	return epoch{slots: make([]epochSlot, n)}
+}
+
+// EpochGuard keeps nodes of a container from being reused while held.
+// The zero EpochGuard does nothing.
+type EpochGuard struct {
+	ep   *epoch
+	e    uint64
+	slot *epochSlot
+}
+
+func (ep *epoch) pin() EpochGuard {
+	// the global generator of math/rand/v2 is per thread, takes no lock.
+	s := &ep.slots[rand.Uint32()&uint32(len(ep.slots)-1)]
+	for {
+		e := ep.global.Load()
+		s.active[e%3].Add(1)
+		// the epoch may have moved on before we were counted, retry.
+		if ep.global.Load() == e {
+			return EpochGuard{ep: ep, e: e, slot: s}
+		}
+		s.active[e%3].Add(-1)
+	}
+}
+
+// pinned returns if any guard may be held in epoch e.
+func (ep *epoch) pinned(e uint64) bool {
+	for i := range ep.slots {
+		if ep.slots[i].active[e%3].Load() != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Unpin releases the guard.
+func (g EpochGuard) Unpin() {
+	if g.ep != nil {
+		g.slot.active[g.e%3].Add(-1)
+	}
+}
+
+// reclaimer recycles retired nodes of type T with free.
+type reclaimer[T any] struct {
+	epoch
+	mu    sync.Mutex
+	limbo [3][]*T
+	free  func(*T)
+}
+
+func newReclaimer[T any](free func(*T)) *reclaimer[T] {
+	return &reclaimer[T]{epoch: newEpoch(), free: free}
+}
+
+// pin returns a guard, or the zero guard if r is nil.
+func (r *reclaimer[T]) pin() EpochGuard {
+	if r == nil {
+		return EpochGuard{}
+	}
+	return r.epoch.pin()
+}
+
+// retire a node that has been unlinked, it is freed when no one pinned
+// before the unlink is left.
+func (r *reclaimer[T]) retire(n *T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g := r.global.Load()
+	r.limbo[g%3] = append(r.limbo[g%3], n)
+
+	// try to advance, (g+2)%3 is the bucket of g-1.
+	old := (g + 2) % 3
+	if r.pinned(old) {
+		return
+	}
+	r.global.Store(g + 1)
+	for i, n := range r.limbo[old] {
+		r.free(n)
+		r.limbo[old][i] = nil
+	}
+	r.limbo[old] = r.limbo[old][:0]
+}
@@ -0,0 +1,141 @@
+package gcl
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestReclaimer(t *testing.T) {
+	freed := make(map[*int]bool)
+	rc := newReclaimer(func(n *int) { freed[n] = true })
+
+	a, b := new(int), new(int)
+	g := rc.pin()
+	rc.retire(a)
+	// a guard pinned before the retire holds a back.
+	for i := 0; i < 10; i++ {
+		rc.retire(new(int))
+	}
+	if freed[a] {
+		t.Fatalf("node freed while pinned")
+	}
+	g.Unpin()
+
+	rc.retire(b)
+	rc.retire(new(int))
+	rc.retire(new(int))
+	if !freed[a] {
+		t.Errorf("node not freed after unpin")
+	}
+
+	var zero EpochGuard
+	zero.Unpin()
+	var nilrc *reclaimer[int]
+	nilrc.pin().Unpin()
+}
+
+// TestReclaimerSlots holds guards pinned in many slots, each holds the
+// nodes retired after it.
+func TestReclaimerSlots(t *testing.T) {
+	freed := make(map[*int]bool)
+	rc := newReclaimer(func(n *int) { freed[n] = true })
+	rc.slots = make([]epochSlot, 8)
+
+	var gs []EpochGuard
+	for i := 0; i < 64; i++ {
+		gs = append(gs, rc.pin())
+	}
+	a := new(int)
+	rc.retire(a)
+	for _, g := range gs {
+		for i := 0; i < 3; i++ {
+			rc.retire(new(int))
+		}
+		if freed[a] {
+			t.Fatalf("node freed while pinned")
+		}
+		g.Unpin()
+	}
+	for i := 0; i < 3; i++ {
+		rc.retire(new(int))
+	}
+	if !freed[a] {
+		t.Errorf("node not freed after unpin")
+	}
+}
+
+func TestReclaimLists(t *testing.T) {
+	sl := NewOrderedSkipList[int, int](WithReclamation(), WithMaxLevel(4))
+	ll := NewOrderedLazyList[int, int](WithListReclamation())
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 100; i++ {
+			sl.Add(i, round)
+			ll.Add(i, round)
+		}
+		for i := 0; i < 100; i++ {
+			if v, ok := sl.Lookup(i); !ok || v != round {
+				t.Fatalf("skiplist lookup %d got %d, %v", i, v, ok)
+			}
+			if v, ok := ll.Lookup(i); !ok || v != round {
+				t.Fatalf("lazylist lookup %d got %d, %v", i, v, ok)
+			}
+			sl.Remove(i)
+			ll.Remove(i)
+		}
+	}
+	if sl.First() != nil || ll.Iterator(0, 100) != nil {
+		t.Errorf("lists should be empty")
+	}
+}
+
+// BenchmarkReclaimChurn adds and removes random keys in a list of steady
+// size, recycled nodes should bring allocations per op down.
+func BenchmarkReclaimChurn(b *testing.B) {
+	const kRange = 10000
+	for _, reclaim := range []bool{false, true} {
+		b.Run(fmt.Sprintf("skiplist-reclaim-%v", reclaim), func(b *testing.B) {
+			var opts []SkiplistOption
+			if reclaim {
+				opts = append(opts, WithReclamation())
+			}
+			list := NewOrderedSkipList[int, int](opts...)
+			for i := 0; i < kRange; i += 2 {
+				list.Add(i, i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					k := r.Intn(kRange)
+					if !list.Add(k, k) {
+						list.Remove(k)
+					}
+				}
+			})
+		})
+
+		b.Run(fmt.Sprintf("lazylist-reclaim-%v", reclaim), func(b *testing.B) {
+			var opts []LazyListOption
+			if reclaim {
+				opts = append(opts, WithListReclamation())
+			}
+			list := NewOrderedLazyList[int, int](opts...)
+			for i := 0; i < kRange/10; i += 2 {
+				list.Add(i, i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					k := r.Intn(kRange / 10)
+					if !list.Add(k, k) {
+						list.Remove(k)
+					}
+				}
+			})
+		})
+	}
+}
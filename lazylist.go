@@ -1,8 +1,13 @@
 package gcl
 
 import (
+	"cmp"
+	"fmt"
+	"iter"
 	"sync"
 	"sync/atomic"
+
+	"github.com/google/uuid"
 )
 
 //
@@ -11,8 +16,11 @@ import (
 //
 
 type lzNode[K any, V any] struct {
-	key    K
-	value  V
+	key   K
+	value V
+	// value replaced by Put or Update, overrides value.   Replaced under
+	// the node lock, read without.
+	upd    atomic.Pointer[V]
 	next   atomic.Pointer[lzNode[K, V]]
 	marked atomic.Bool
 	sync.Mutex
@@ -26,35 +34,203 @@ func (n *lzNode[K, V]) isNotMarked() bool {
 	return !n.marked.Load()
 }
 
-// LzIter is an iterator for LazyList
+func (n *lzNode[K, V]) getValue() V {
+	if p := n.upd.Load(); p != nil {
+		return *p
+	}
+	return n.value
+}
+
+// LzIter is an iterator for LazyList, over keys in [ka, kz).   It keeps
+// the key it is at, so if its node is removed concurrently, it resumes
+// from the key instead of the removed node.   With WithListReclamation it
+// must be guarded by Pin.
 type LzIter[K any, V any] struct {
+	l *LazyList[K, V]
 	// range [ka, kz)
 	ka, kz K
-	curr   *lzNode[K, V]
+	// nil when not valid.
+	curr *lzNode[K, V]
+	key  K
 }
 
 // GetKey returns the key of the current node of the iterator
 func (it *LzIter[K, V]) GetKey() K {
-	return it.curr.key
+	return it.key
 }
 
 // GetValue returns the value of the current node of the iterator
 func (it *LzIter[K, V]) GetValue() V {
-	return it.curr.value
+	return it.curr.getValue()
+}
+
+// Valid returns if the iterator is at a key.   It is not once it moves
+// out of the range.
+func (it *LzIter[K, V]) Valid() bool {
+	return it.curr != nil
+}
+
+// set moves the iterator to n, or makes it not valid if n is out of range.
+func (it *LzIter[K, V]) set(n *lzNode[K, V]) bool {
+	l := it.l
+	if n == nil || n == l.tail || l.cmp(n.key, it.kz) >= 0 || l.cmp(n.key, it.ka) < 0 {
+		it.curr = nil
+		return false
+	}
+	it.curr, it.key = n, n.key
+	return true
+}
+
+// seek moves to the first key >= k, or > k if after.
+func (it *LzIter[K, V]) seek(k K, after bool) bool {
+	l := it.l
+	if l.cmp(k, it.ka) < 0 {
+		k, after = it.ka, false
+	}
+	_, curr, found := l.find(k, true)
+	if found && after {
+		curr = l.skip(curr.next.Load())
+	}
+	return it.set(curr)
+}
+
+// Seek moves to the first key >= k in range, and returns if it is valid.
+func (it *LzIter[K, V]) Seek(k K) bool {
+	return it.seek(k, false)
+}
+
+// SeekAfter moves to the first key > k in range, and returns if it is
+// valid.   A scan can be resumed with the last key it saw.
+func (it *LzIter[K, V]) SeekAfter(k K) bool {
+	return it.seek(k, true)
+}
+
+// SeekLast moves to the last key in range, in O(n).
+func (it *LzIter[K, V]) SeekLast() bool {
+	return it.set(it.l.before(it.kz))
+}
+
+// Next moves to the next key, and returns if it is valid.
+func (it *LzIter[K, V]) Next() bool {
+	if it.curr == nil {
+		return false
+	}
+	if it.curr.isMarked() {
+		// removed, its next may be stale, resume from its key.
+		return it.seek(it.key, true)
+	}
+	return it.set(it.l.skip(it.curr.next.Load()))
+}
+
+// Prev moves to the previous key, in O(n), and returns if it is valid.
+func (it *LzIter[K, V]) Prev() bool {
+	if it.curr == nil {
+		return false
+	}
+	return it.set(it.l.before(it.key))
 }
 
 type LazyList[K any, V any] struct {
 	// sentinel nodes
 	head, tail *lzNode[K, V]
-	less       func(a, b K) bool
-	eq         func(a, b K) bool
+	cmp        func(a, b K) int
+	// optional node recycling
+	rc   *reclaimer[lzNode[K, V]]
+	pool sync.Pool
+	// number of keys, on its own cache line.
+	cnt paddedCounter
 }
 
-func NewLazyList[K any, V any](less func(a, b K) bool, eq func(a, b K) bool) *LazyList[K, V] {
+// LazyListOption configures a LazyList at construction time.
+type LazyListOption func(*lazyListConfig)
+
+type lazyListConfig struct {
+	reclaim bool
+}
+
+// WithListReclamation recycles removed nodes once no operation can still
+// reach them, see epoch.go.   Iterators must be guarded by Pin.
+func WithListReclamation() LazyListOption {
+	return func(c *lazyListConfig) {
+		c.reclaim = true
+	}
+}
+
+func NewLazyList[K any, V any](less func(a, b K) bool, eq func(a, b K) bool, opts ...LazyListOption) *LazyList[K, V] {
+	return NewLazyListFunc[K, V](cmpOf(less, eq), opts...)
+}
+
+// NewLazyListFunc creates a list ordered by the three-way comparator cmp.
+func NewLazyListFunc[K any, V any](cmp func(a, b K) int, opts ...LazyListOption) *LazyList[K, V] {
+	var cfg lazyListConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	head := &lzNode[K, V]{}
 	tail := &lzNode[K, V]{}
 	head.next.Store(tail)
-	return &LazyList[K, V]{head: head, tail: tail, less: less, eq: eq}
+	l := &LazyList[K, V]{head: head, tail: tail, cmp: cmp}
+	if cfg.reclaim {
+		l.rc = newReclaimer(l.recycle)
+	}
+	return l
+}
+
+// NewOrderedLazyList creates a list of cmp.Ordered keys.
+func NewOrderedLazyList[K cmp.Ordered, V any](opts ...LazyListOption) *LazyList[K, V] {
+	return NewLazyListFunc[K, V](cmp.Compare[K], opts...)
+}
+
+// NewUUIDLazyList creates a list of uuid.UUID keys.
+func NewUUIDLazyList[V any](opts ...LazyListOption) *LazyList[uuid.UUID, V] {
+	return NewLazyListFunc[uuid.UUID, V](UUIDCmp, opts...)
+}
+
+// newNode creates a new node, from the pool if nodes are recycled.
+func (l *LazyList[K, V]) newNode(key K, val V) *lzNode[K, V] {
+	if l.rc != nil {
+		if n, ok := l.pool.Get().(*lzNode[K, V]); ok {
+			n.key, n.value = key, val
+			return n
+		}
+	}
+	return &lzNode[K, V]{key: key, value: val}
+}
+
+// recycle resets a retired node and puts it in the pool.
+func (l *LazyList[K, V]) recycle(n *lzNode[K, V]) {
+	var zk K
+	var zv V
+	n.key, n.value = zk, zv
+	n.upd.Store(nil)
+	n.next.Store(nil)
+	n.marked.Store(false)
+	l.pool.Put(n)
+}
+
+// Pin keeps nodes from being recycled until the guard is unpinned.  It is
+// only needed to hold iterators with WithListReclamation.
+func (l *LazyList[K, V]) Pin() EpochGuard {
+	return l.rc.pin()
+}
+
+// find returns pred and curr, where curr is the first node not less than
+// key, and if curr has key.   Marked nodes are skipped if skipMarked.
+func (l *LazyList[K, V]) find(key K, skipMarked bool) (*lzNode[K, V], *lzNode[K, V], bool) {
+	pred := l.head
+	curr := pred.next.Load()
+	for curr != l.tail {
+		if !skipMarked || curr.isNotMarked() {
+			c := l.cmp(curr.key, key)
+			if c >= 0 {
+				return pred, curr, c == 0
+			}
+		}
+		pred = curr
+		curr = pred.next.Load()
+	}
+	return pred, curr, false
 }
 
 // Validate that pred and curr are still in the list and adjacent
@@ -62,21 +238,23 @@ func (l *LazyList[K, V]) validate(pred, curr *lzNode[K, V]) bool {
 	return pred.isNotMarked() && curr.isNotMarked() && pred.next.Load() == curr
 }
 
-// return if pred, curr are still valid, and if valid, the result of add
-func (l *LazyList[K, V]) lockAdd(pred, curr *lzNode[K, V], key K, val V) (bool, bool) {
+// return if pred, curr are still valid, and if valid, the result of add.
+// found is if curr has key, keys never change so it holds if valid.
+func (l *LazyList[K, V]) lockAdd(pred, curr *lzNode[K, V], found bool, key K, val V) (bool, bool) {
 	pred.Lock()
 	defer pred.Unlock()
 	curr.Lock()
 	defer curr.Unlock()
 	if l.validate(pred, curr) {
-		if l.eq(curr.key, key) {
+		if found {
 			// already exists.   return valid but not added
 			return true, false
 		} else {
 			// added kv
-			newNode := &lzNode[K, V]{key: key, value: val}
+			newNode := l.newNode(key, val)
 			newNode.next.Store(curr)
 			pred.next.Store(newNode)
+			l.cnt.Add(1)
 			return true, true
 		}
 	}
@@ -85,27 +263,11 @@ func (l *LazyList[K, V]) lockAdd(pred, curr *lzNode[K, V], key K, val V) (bool,
 
 // Add key, val to the list.  Return true if added, false if already exists
 func (l *LazyList[K, V]) Add(key K, val V) bool {
+	defer l.rc.pin().Unpin()
 	for {
-		pred := l.head
-		curr := pred.next.Load()
-		// walk the list
-		for curr != l.tail {
-			// if curr is marked for deletion, move to next.
-			marked := curr.isMarked()
-			if marked {
-				pred = curr
-				curr = pred.next.Load()
-			} else {
-				if l.less(curr.key, key) {
-					pred = curr
-					curr = pred.next.Load()
-				} else {
-					break
-				}
-			}
-		}
-
-		valid, ret := l.lockAdd(pred, curr, key, val)
+		// walk the list, if curr is marked for deletion, move to next.
+		pred, curr, found := l.find(key, true)
+		valid, ret := l.lockAdd(pred, curr, found, key, val)
 		if valid {
 			return ret
 		}
@@ -113,7 +275,7 @@ func (l *LazyList[K, V]) Add(key K, val V) bool {
 }
 
 // return if pred, curr are still valid, and if valid, the result of remove
-func (l *LazyList[K, V]) lockRemove(pred, curr *lzNode[K, V], key K) (bool, bool) {
+func (l *LazyList[K, V]) lockRemove(pred, curr *lzNode[K, V], found bool) (bool, bool) {
 	pred.Lock()
 	defer pred.Unlock()
 	curr.Lock()
@@ -121,7 +283,7 @@ func (l *LazyList[K, V]) lockRemove(pred, curr *lzNode[K, V], key K) (bool, bool
 
 	// still valid?
 	if l.validate(pred, curr) {
-		if !l.eq(curr.key, key) {
+		if !found {
 			// not found
 			return true, false
 		} else {
@@ -129,6 +291,7 @@ func (l *LazyList[K, V]) lockRemove(pred, curr *lzNode[K, V], key K) (bool, bool
 			curr.marked.Store(true)
 			// physical remove
 			pred.next.Store(curr.next.Load())
+			l.cnt.Add(-1)
 			return true, true
 		}
 	}
@@ -137,56 +300,269 @@ func (l *LazyList[K, V]) lockRemove(pred, curr *lzNode[K, V], key K) (bool, bool
 
 // Remove key from the list.  Return true if removed, false if not found
 func (l *LazyList[K, V]) Remove(key K) bool {
+	defer l.rc.pin().Unpin()
 	for {
-		pred := l.head
-		curr := pred.next.Load()
 		// walk the list
-		for curr != l.tail && l.less(curr.key, key) {
-			pred = curr
-			curr = pred.next.Load()
-		}
-
-		valid, ret := l.lockRemove(pred, curr, key)
+		pred, curr, found := l.find(key, false)
+		valid, ret := l.lockRemove(pred, curr, found)
 		if valid {
+			if ret && l.rc != nil {
+				l.rc.retire(curr)
+			}
 			return ret
 		}
 	} // for loop
 }
 
+// lzOp is what compute does with a key.
+type lzOp int
+
+const (
+	lzKeep lzOp = iota
+	lzStore
+	lzDelete
+)
+
+// lockCompute returns if pred, curr are still valid, and if valid, the
+// value of key before fn, if it was there, and the node removed, if any.
+func (l *LazyList[K, V]) lockCompute(pred, curr *lzNode[K, V], found bool, key K, fn func(old V, loaded bool) (V, lzOp)) (bool, V, bool, *lzNode[K, V]) {
+	pred.Lock()
+	defer pred.Unlock()
+	curr.Lock()
+	defer curr.Unlock()
+
+	var old V
+	if !l.validate(pred, curr) {
+		return false, old, false, nil
+	}
+	if found {
+		old = curr.getValue()
+	}
+	nv, op := fn(old, found)
+	switch {
+	case op == lzStore && found:
+		curr.upd.Store(&nv)
+	case op == lzStore:
+		newNode := l.newNode(key, nv)
+		newNode.next.Store(curr)
+		pred.next.Store(newNode)
+		l.cnt.Add(1)
+	case op == lzDelete && found:
+		curr.marked.Store(true)
+		pred.next.Store(curr.next.Load())
+		l.cnt.Add(-1)
+		return true, old, found, curr
+	}
+	return true, old, found, nil
+}
+
+// compute calls fn with the value of key, if it is there, with pred and
+// curr locked, and stores or deletes as fn returns.   It returns the value
+// before fn and if key was there.
+func (l *LazyList[K, V]) compute(key K, fn func(old V, loaded bool) (V, lzOp)) (V, bool) {
+	defer l.rc.pin().Unpin()
+	for {
+		pred, curr, found := l.find(key, true)
+		valid, old, loaded, removed := l.lockCompute(pred, curr, found, key, fn)
+		if valid {
+			if removed != nil && l.rc != nil {
+				l.rc.retire(removed)
+			}
+			return old, loaded
+		}
+	}
+}
+
+// Put sets the value of key, adding key if it is not there.   It returns
+// true if key was added.
+func (l *LazyList[K, V]) Put(key K, val V) bool {
+	_, loaded := l.compute(key, func(V, bool) (V, lzOp) {
+		return val, lzStore
+	})
+	return !loaded
+}
+
+// Update atomically replaces the value of key with the result of fn, which
+// gets the old value and if key was there.   If fn returns del, key is
+// removed.   It returns the new value and if key is there.   fn runs with
+// nodes locked, it must not use the list.
+func (l *LazyList[K, V]) Update(key K, fn func(old V, loaded bool) (nv V, del bool)) (V, bool) {
+	var nv V
+	var del bool
+	l.compute(key, func(old V, loaded bool) (V, lzOp) {
+		if nv, del = fn(old, loaded); del {
+			return nv, lzDelete
+		}
+		return nv, lzStore
+	})
+	if del {
+		var zero V
+		return zero, false
+	}
+	return nv, true
+}
+
+// LoadOrStore returns the value of key if it is there, otherwise it adds
+// key with val and returns val.   loaded is true if the value was loaded.
+func (l *LazyList[K, V]) LoadOrStore(key K, val V) (actual V, loaded bool) {
+	old, loaded := l.compute(key, func(old V, loaded bool) (V, lzOp) {
+		if loaded {
+			return old, lzKeep
+		}
+		return val, lzStore
+	})
+	if loaded {
+		return old, true
+	}
+	return val, false
+}
+
+// LoadAndDelete removes key, returning its value if it was there.
+func (l *LazyList[K, V]) LoadAndDelete(key K) (V, bool) {
+	return l.compute(key, func(old V, loaded bool) (V, lzOp) {
+		return old, lzDelete
+	})
+}
+
+// Len returns the number of keys.   It is exact when there are no
+// concurrent writers.
+func (l *LazyList[K, V]) Len() int {
+	return int(l.cnt.Load())
+}
+
 // Lookup
 func (l *LazyList[K, V]) Lookup(key K) (V, bool) {
-	curr := l.head.next.Load()
-	for curr != l.tail && l.less(curr.key, key) {
-		curr = curr.next.Load()
-	}
-	if l.eq(curr.key, key) && curr.isNotMarked() {
-		return curr.value, true
+	defer l.rc.pin().Unpin()
+	_, curr, found := l.find(key, false)
+	if found && curr.isNotMarked() {
+		return curr.getValue(), true
 	}
 	return l.head.value, false
 }
 
-// Iterator
-func (l *LazyList[K, V]) Iterator(ka, kz K) *LzIter[K, V] {
-	curr := l.head.next.Load()
-	for curr != l.tail && (curr.isNotMarked() && l.less(curr.key, ka)) {
+// Contains returns if key is in the list.
+func (l *LazyList[K, V]) Contains(key K) bool {
+	_, ok := l.Lookup(key)
+	return ok
+}
+
+// LookupMany looks up keys and returns their values, and if each key was
+// found.   Each search starts from the node before the previous key, so
+// keys in ascending order walk the list once.   Keys out of order restart
+// from the head.   A key there during the whole call is found, one added
+// or removed concurrently may or may not be.
+func (l *LazyList[K, V]) LookupMany(keys []K) ([]V, []bool) {
+	defer l.rc.pin().Unpin()
+	vals := make([]V, len(keys))
+	found := make([]bool, len(keys))
+	pred := l.head
+	for i, k := range keys {
+		if i > 0 && l.cmp(keys[i-1], k) > 0 {
+			pred = l.head
+		}
+		curr := pred.next.Load()
+		for curr != l.tail {
+			c := l.cmp(curr.key, k)
+			if c >= 0 {
+				if c == 0 && curr.isNotMarked() {
+					vals[i], found[i] = curr.getValue(), true
+				}
+				break
+			}
+			pred = curr
+			curr = pred.next.Load()
+		}
+	}
+	return vals, found
+}
+
+// skip returns the first node from curr that is not marked.
+func (l *LazyList[K, V]) skip(curr *lzNode[K, V]) *lzNode[K, V] {
+	for curr != l.tail && curr.isMarked() {
 		curr = curr.next.Load()
 	}
+	return curr
+}
 
-	if curr == l.tail || !l.less(curr.key, kz) {
-		return nil
+// before returns the last node not marked with key < k, nil if none.
+func (l *LazyList[K, V]) before(k K) *lzNode[K, V] {
+	var last *lzNode[K, V]
+	for curr := l.head.next.Load(); curr != l.tail && l.cmp(curr.key, k) < 0; curr = curr.next.Load() {
+		if curr.isNotMarked() {
+			last = curr
+		}
 	}
+	return last
+}
 
-	return &LzIter[K, V]{ka: ka, kz: kz, curr: curr}
+// Cursor returns an iterator over keys in [ka, kz), at the first key.
+// Unlike Iterator, it is returned even if the range is empty, check Valid.
+func (l *LazyList[K, V]) Cursor(ka, kz K) *LzIter[K, V] {
+	it := &LzIter[K, V]{l: l, ka: ka, kz: kz}
+	it.seek(ka, false)
+	return it
 }
 
+// Iterator returns an iterator at the first key in [ka, kz), nil if there
+// are none.
+func (l *LazyList[K, V]) Iterator(ka, kz K) *LzIter[K, V] {
+	if it := l.Cursor(ka, kz); it.Valid() {
+		return it
+	}
+	return nil
+}
+
+// Next moves it to the next key, it returns nil at the end of the range.
 func (l *LazyList[K, V]) Next(it *LzIter[K, V]) *LzIter[K, V] {
-	it.curr = it.curr.next.Load()
-	for it.curr != l.tail && it.curr.isMarked() {
-		it.curr = it.curr.next.Load()
+	if it.Next() {
+		return it
 	}
+	return nil
+}
 
-	if it.curr == l.tail || !l.less(it.curr.key, it.kz) {
-		return nil
+// All iterates the key value pairs in order.   Like the iterator, keys
+// added or removed concurrently may or may not be seen.
+func (l *LazyList[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		defer l.Pin().Unpin()
+		for curr := l.head.next.Load(); curr != l.tail; curr = curr.next.Load() {
+			if curr.isNotMarked() && !yield(curr.key, curr.getValue()) {
+				return
+			}
+		}
 	}
-	return it
+}
+
+// from iterates the key value pairs from the first key >= k, in order.
+func (l *LazyList[K, V]) from(k K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		defer l.Pin().Unpin()
+		_, curr, _ := l.find(k, false)
+		for ; curr != l.tail; curr = curr.next.Load() {
+			if curr.isNotMarked() && !yield(curr.key, curr.getValue()) {
+				return
+			}
+		}
+	}
+}
+
+// appendSorted appends key value pairs in strictly ascending order, all
+// greater than keys already in the list.   Not safe with concurrent writers.
+func (l *LazyList[K, V]) appendSorted(seq iter.Seq2[K, V]) error {
+	last := l.head
+	for curr := last.next.Load(); curr != l.tail; curr = curr.next.Load() {
+		last = curr
+	}
+
+	for k, v := range seq {
+		if last != l.head && l.cmp(last.key, k) >= 0 {
+			return fmt.Errorf("lazylist input is not sorted")
+		}
+		nn := l.newNode(k, v)
+		nn.next.Store(l.tail)
+		last.next.Store(nn)
+		last = nn
+		l.cnt.Add(1)
+	}
+	return nil
 }
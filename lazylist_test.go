@@ -1,9 +1,13 @@
 package gcl
 
 import (
+	"cmp"
 	"math/rand"
+	"slices"
 	"sync"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestLazyList(t *testing.T) {
@@ -82,3 +86,217 @@ func TestLazyList(t *testing.T) {
 		t.Errorf("counting error %d %d %d", remCnt, cnt, insCnt)
 	}
 }
+
+// TestLazyListZeroKey uses the zero key, which the tail sentinel holds.
+// The tail used to compare equal to it, so the zero key was found in an
+// empty list, could not be added, and removing it removed the tail.
+func TestLazyListZeroKey(t *testing.T) {
+	list := NewOrderedLazyList[int, int]()
+	if _, ok := list.Lookup(0); ok {
+		t.Errorf("zero key found in an empty list")
+	}
+	if list.Remove(0) {
+		t.Errorf("removed zero key from an empty list")
+	}
+	if !list.Add(0, 1) {
+		t.Fatalf("add zero key failed")
+	}
+	if v, ok := list.Lookup(0); !ok || v != 1 {
+		t.Errorf("lookup zero key got %d, %v", v, ok)
+	}
+	if !list.Remove(0) || list.Remove(0) {
+		t.Errorf("remove zero key twice")
+	}
+	if _, ok := list.Lookup(0); ok {
+		t.Errorf("zero key found after remove")
+	}
+	list.Add(1, 1)
+	list.Add(-1, -1)
+	if _, ok := list.Lookup(0); ok || list.Remove(0) {
+		t.Errorf("zero key found between -1 and 1")
+	}
+}
+
+func TestLazyListCmp(t *testing.T) {
+	var calls int
+	list := NewLazyListFunc[int, int](func(a, b int) int {
+		calls++
+		return cmp.Compare(a, b)
+	})
+	for i := 0; i < 100; i++ {
+		list.Add(i, i)
+	}
+
+	// one comparator call per node visited.
+	calls = 0
+	if v, ok := list.Lookup(42); !ok || v != 42 {
+		t.Fatalf("lookup 42 got %d, %v", v, ok)
+	}
+	if calls != 43 {
+		t.Errorf("lookup 42 made %d comparator calls, want 43", calls)
+	}
+
+	ol := NewOrderedLazyList[string, int]()
+	ol.Add("b", 2)
+	ol.Add("a", 1)
+	if v, ok := ol.Lookup("a"); !ok || v != 1 {
+		t.Errorf("ordered lookup got %d, %v", v, ok)
+	}
+
+	ul := NewUUIDLazyList[int]()
+	id := uuid.New()
+	ul.Add(id, 1)
+	if !ul.Remove(id) || ul.Remove(id) {
+		t.Errorf("uuid remove failed")
+	}
+}
+
+func TestLazyListUpdate(t *testing.T) {
+	list := NewOrderedLazyList[int, int](WithListReclamation())
+	if !list.Put(1, 1) || list.Put(1, 2) || list.Len() != 1 {
+		t.Errorf("put should add then replace, len %d", list.Len())
+	}
+	if v, loaded := list.LoadOrStore(1, 3); !loaded || v != 2 {
+		t.Errorf("LoadOrStore existing got %d, %v", v, loaded)
+	}
+	if v, loaded := list.LoadOrStore(2, 3); loaded || v != 3 {
+		t.Errorf("LoadOrStore new got %d, %v", v, loaded)
+	}
+	if v, ok := list.LoadAndDelete(2); !ok || v != 3 || list.Len() != 1 {
+		t.Errorf("LoadAndDelete got %d, %v, len %d", v, ok, list.Len())
+	}
+	if _, ok := list.LoadAndDelete(2); ok {
+		t.Errorf("LoadAndDelete of a missing key")
+	}
+	if v, ok := list.Update(1, func(old int, loaded bool) (int, bool) { return 0, true }); ok || v != 0 {
+		t.Errorf("Update delete got %d, %v", v, ok)
+	}
+
+	const (
+		thCnt   = 8
+		loopCnt = 1000
+		kRange  = 10
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < thCnt; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				list.Update(j%kRange, func(old int, loaded bool) (int, bool) {
+					return old + 1, false
+				})
+				// churn other keys, so that nodes are recycled.
+				list.Put(kRange+j%kRange, j)
+				list.LoadAndDelete(kRange + (j+5)%kRange)
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for k, v := range list.All() {
+		if k < kRange {
+			total += v
+		}
+	}
+	if total != thCnt*loopCnt {
+		t.Errorf("lost updates, total %d, want %d", total, thCnt*loopCnt)
+	}
+	cnt := 0
+	for range list.All() {
+		cnt++
+	}
+	if list.Len() != cnt {
+		t.Errorf("len %d, want %d", list.Len(), cnt)
+	}
+}
+
+func TestLazyListCursor(t *testing.T) {
+	list := NewOrderedLazyList[int, int]()
+	for i := 0; i < 20; i += 2 {
+		list.Add(i, i*10)
+	}
+
+	if it := list.Cursor(5, 6); it.Valid() || it.Next() || it.Prev() {
+		t.Errorf("empty range is valid")
+	}
+
+	it := list.Cursor(3, 15)
+	var keys []int
+	for ; it.Valid(); it.Next() {
+		keys = append(keys, it.GetKey())
+	}
+	if !slices.Equal(keys, []int{4, 6, 8, 10, 12, 14}) {
+		t.Errorf("forward %v", keys)
+	}
+
+	keys = keys[:0]
+	for ok := it.SeekLast(); ok; ok = it.Prev() {
+		keys = append(keys, it.GetKey())
+	}
+	if !slices.Equal(keys, []int{14, 12, 10, 8, 6, 4}) {
+		t.Errorf("backward %v", keys)
+	}
+
+	if !it.Seek(7) || it.GetKey() != 8 || it.GetValue() != 80 {
+		t.Errorf("seek 7 at %d", it.GetKey())
+	}
+	if !it.SeekAfter(8) || it.GetKey() != 10 {
+		t.Errorf("seek after 8 at %d", it.GetKey())
+	}
+	if it.SeekAfter(14) || it.Seek(100) {
+		t.Errorf("seek past the range is valid")
+	}
+	if !it.Seek(0) || it.GetKey() != 4 {
+		t.Errorf("seek before the range at %d", it.GetKey())
+	}
+
+	// the current node is removed and a key is added right after it, the
+	// cursor resumes from its key and sees the new key.
+	it.Seek(10)
+	list.Remove(10)
+	list.Add(11, 110)
+	list.Remove(12)
+	if !it.Next() || it.GetKey() != 11 {
+		t.Errorf("next after remove at %d", it.GetKey())
+	}
+
+	// paginated scan, pages of 3, resumed by key.
+	page := func(after int) []int {
+		c := list.Cursor(0, 100)
+		var ks []int
+		for ok := c.SeekAfter(after); ok && len(ks) < 3; ok = c.Next() {
+			ks = append(ks, c.GetKey())
+		}
+		return ks
+	}
+	p1 := page(-1)
+	list.Remove(p1[2])
+	p2 := page(p1[2])
+	if !slices.Equal(p1, []int{0, 2, 4}) || !slices.Equal(p2, []int{6, 8, 11}) {
+		t.Errorf("pages %v %v", p1, p2)
+	}
+}
+
+func TestLazyListLookupMany(t *testing.T) {
+	list := NewOrderedLazyList[int, int]()
+	for i := 0; i < 1000; i += 2 {
+		list.Add(i, i*10)
+	}
+	keys := []int{5, 4, 999, 998, 0, -1, 4, 4, 1000, 3}
+	for _, sorted := range []bool{false, true} {
+		if sorted {
+			slices.Sort(keys)
+		}
+		vals, found := list.LookupMany(keys)
+		for i, k := range keys {
+			if v, ok := list.Lookup(k); ok != found[i] || v != vals[i] {
+				t.Fatalf("key %d got %d, %v, want %d, %v", k, vals[i], found[i], v, ok)
+			}
+		}
+	}
+	if !list.Contains(2) || list.Contains(3) {
+		t.Errorf("contains")
+	}
+}
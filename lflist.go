@@ -0,0 +1,230 @@
+package gcl
+
+import (
+	"cmp"
+	"iter"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+//
+// lock free sorted list, no duplicate keys.   See the LockFreeList
+// described in The Art of Multiprocessor Programming, Chapter 9, after
+// Harris and Michael.
+//
+// A node is removed logically by marking its next reference, then
+// physically by a CAS on the next reference of its predecessor.
+// Traversals of Add and Remove help, they unlink every marked node they
+// pass.   The markable references are per node pairs, as in lfskiplist.go.
+//
+
+type lfListRef[K any, V any] struct {
+	node   *lfListNode[K, V]
+	marked bool
+}
+
+type lfListNode[K any, V any] struct {
+	key   K
+	value V
+	refs  [2]lfListRef[K, V]
+	next  atomic.Pointer[lfListRef[K, V]]
+}
+
+// ref returns the (n, marked) pair.
+func (n *lfListNode[K, V]) ref(marked bool) *lfListRef[K, V] {
+	if marked {
+		return &n.refs[1]
+	}
+	return &n.refs[0]
+}
+
+func (n *lfListNode[K, V]) isMarked() bool {
+	return n.next.Load().marked
+}
+
+func newLfListNode[K any, V any](k K, v V) *lfListNode[K, V] {
+	n := &lfListNode[K, V]{key: k, value: v}
+	n.refs[0].node = n
+	n.refs[1] = lfListRef[K, V]{node: n, marked: true}
+	return n
+}
+
+// LfIter is an iterator for LockFreeList
+type LfIter[K any, V any] struct {
+	// range [ka, kz)
+	ka, kz K
+	curr   *lfListNode[K, V]
+}
+
+// GetKey returns the key of the current node of the iterator
+func (it *LfIter[K, V]) GetKey() K {
+	return it.curr.key
+}
+
+// GetValue returns the value of the current node of the iterator
+func (it *LfIter[K, V]) GetValue() V {
+	return it.curr.value
+}
+
+type LockFreeList[K any, V any] struct {
+	// sentinel nodes
+	head, tail *lfListNode[K, V]
+	cmp        func(a, b K) int
+}
+
+// NewLockFreeList creates a lock free list ordered by less, with eq for
+// equality.
+func NewLockFreeList[K any, V any](less, eq func(a, b K) bool) *LockFreeList[K, V] {
+	return NewLockFreeListFunc[K, V](cmpOf(less, eq))
+}
+
+// NewLockFreeListFunc creates a lock free list ordered by the three-way
+// comparator cmp.
+func NewLockFreeListFunc[K any, V any](cmp func(a, b K) int) *LockFreeList[K, V] {
+	var zk K
+	var zv V
+	l := &LockFreeList[K, V]{
+		head: newLfListNode(zk, zv),
+		tail: newLfListNode(zk, zv),
+		cmp:  cmp,
+	}
+	l.head.next.Store(l.tail.ref(false))
+	l.tail.next.Store(l.tail.ref(false))
+	return l
+}
+
+// NewOrderedLockFreeList creates a lock free list of cmp.Ordered keys.
+func NewOrderedLockFreeList[K cmp.Ordered, V any]() *LockFreeList[K, V] {
+	return NewLockFreeListFunc[K, V](cmp.Compare[K])
+}
+
+// NewUUIDLockFreeList creates a lock free list of uuid.UUID keys.
+func NewUUIDLockFreeList[V any]() *LockFreeList[uuid.UUID, V] {
+	return NewLockFreeListFunc[uuid.UUID, V](UUIDCmp)
+}
+
+// find returns pred and curr, curr is the first node with key >= key and
+// pred is before it, both not marked when seen.   Marked nodes on the
+// way are unlinked.
+func (l *LockFreeList[K, V]) find(key K) (*lfListNode[K, V], *lfListNode[K, V], bool) {
+retry:
+	for {
+		pred := l.head
+		curr := pred.next.Load().node
+		for curr != l.tail {
+			succ := curr.next.Load()
+			for succ.marked {
+				// curr is being removed, help unlink it.
+				if !pred.next.CompareAndSwap(curr.ref(false), succ.node.ref(false)) {
+					continue retry
+				}
+				curr = succ.node
+				if curr == l.tail {
+					return pred, curr, false
+				}
+				succ = curr.next.Load()
+			}
+			if c := l.cmp(curr.key, key); c >= 0 {
+				return pred, curr, c == 0
+			}
+			pred = curr
+			curr = succ.node
+		}
+		return pred, curr, false
+	}
+}
+
+// Add key, val to the list.  Return true if added, false if already exists
+func (l *LockFreeList[K, V]) Add(key K, val V) bool {
+	var nn *lfListNode[K, V]
+	for {
+		pred, curr, found := l.find(key)
+		if found {
+			return false
+		}
+		if nn == nil {
+			nn = newLfListNode(key, val)
+		}
+		nn.next.Store(curr.ref(false))
+		if pred.next.CompareAndSwap(curr.ref(false), nn.ref(false)) {
+			return true
+		}
+	}
+}
+
+// Remove key from the list.  Return true if removed, false if not found
+func (l *LockFreeList[K, V]) Remove(key K) bool {
+	for {
+		pred, curr, found := l.find(key)
+		if !found {
+			return false
+		}
+		// marking is the linearization point, whoever marks removes it.
+		succ := curr.next.Load()
+		if succ.marked || !curr.next.CompareAndSwap(succ, succ.node.ref(true)) {
+			continue
+		}
+		// try to unlink, or leave it to the next traversal.
+		pred.next.CompareAndSwap(curr.ref(false), succ.node.ref(false))
+		return true
+	}
+}
+
+// Lookup is wait free, it skips marked nodes without unlinking them.
+func (l *LockFreeList[K, V]) Lookup(key K) (V, bool) {
+	curr := l.head.next.Load().node
+	for curr != l.tail {
+		if c := l.cmp(curr.key, key); c >= 0 {
+			if c == 0 && !curr.isMarked() {
+				return curr.value, true
+			}
+			break
+		}
+		curr = curr.next.Load().node
+	}
+	return l.head.value, false
+}
+
+// skip returns the first node from curr that is not marked.
+func (l *LockFreeList[K, V]) skip(curr *lfListNode[K, V]) *lfListNode[K, V] {
+	for curr != l.tail && curr.isMarked() {
+		curr = curr.next.Load().node
+	}
+	return curr
+}
+
+// Iterator returns an iterator over keys in [ka, kz), nil if there are
+// none.
+func (l *LockFreeList[K, V]) Iterator(ka, kz K) *LfIter[K, V] {
+	curr := l.head.next.Load().node
+	for curr != l.tail && (curr.isMarked() || l.cmp(curr.key, ka) < 0) {
+		curr = curr.next.Load().node
+	}
+
+	if curr == l.tail || l.cmp(curr.key, kz) >= 0 {
+		return nil
+	}
+	return &LfIter[K, V]{ka: ka, kz: kz, curr: curr}
+}
+
+// Next moves the iterator to the next key, it returns nil past kz.
+func (l *LockFreeList[K, V]) Next(it *LfIter[K, V]) *LfIter[K, V] {
+	it.curr = l.skip(it.curr.next.Load().node)
+	if it.curr == l.tail || l.cmp(it.curr.key, it.kz) >= 0 {
+		return nil
+	}
+	return it
+}
+
+// All iterates the key value pairs in order.   Like the iterator, keys
+// added or removed concurrently may or may not be seen.
+func (l *LockFreeList[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for curr := l.skip(l.head.next.Load().node); curr != l.tail; curr = l.skip(curr.next.Load().node) {
+			if !yield(curr.key, curr.value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package gcl
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestLockFreeList(t *testing.T) {
+	const (
+		loopCnt  = 1000
+		thCnt    = 10
+		keyRange = 20
+	)
+
+	list := NewOrderedLockFreeList[int64, int64]()
+	var insCnt, remCnt [thCnt]int
+	var wg sync.WaitGroup
+	for i := 0; i < thCnt; i++ {
+		wg.Add(1)
+		go func(ii int) {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				ikey := rand.Int63() % keyRange
+				if list.Add(ikey, int64(ii)) {
+					insCnt[ii]++
+				}
+				if v, ok := list.Lookup(ikey); ok && v != int64(ii) && list.Remove(ikey) {
+					remCnt[ii]++
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cnt := 0
+	for range list.All() {
+		cnt++
+	}
+	ins, rem := 0, 0
+	for i := range insCnt {
+		ins += insCnt[i]
+		rem += remCnt[i]
+	}
+	if rem+cnt != ins {
+		t.Errorf("counting error %d %d %d", rem, cnt, ins)
+	}
+}
+
+func TestLockFreeListIterator(t *testing.T) {
+	list := NewOrderedLockFreeList[int, int]()
+	for i := 0; i < 10; i++ {
+		list.Add(i, i*10)
+	}
+	list.Remove(3)
+	list.Remove(4)
+
+	var got []int
+	for it := list.Iterator(2, 7); it != nil; it = list.Next(it) {
+		got = append(got, it.GetKey(), it.GetValue())
+	}
+	if fmt.Sprint(got) != "[2 20 5 50 6 60]" {
+		t.Errorf("iterated %v", got)
+	}
+	if list.Iterator(3, 5) != nil || list.Iterator(10, 20) != nil {
+		t.Errorf("empty range has an iterator")
+	}
+
+	ul := NewUUIDLockFreeList[int]()
+	id := uuid.New()
+	ul.Add(id, 1)
+	if !ul.Remove(id) || ul.Remove(id) {
+		t.Errorf("uuid remove failed")
+	}
+}
+
+// listAPI is the common api of LazyList and LockFreeList.
+type listAPI interface {
+	Add(k, v int) bool
+	Remove(k int) bool
+	Lookup(k int) (int, bool)
+}
+
+// BenchmarkListContention runs a fixed number of goroutines, half of the
+// operations are writes on a short list, so they contend on its nodes.
+func BenchmarkListContention(b *testing.B) {
+	const kRange = 64
+	lists := []struct {
+		name string
+		mk   func() listAPI
+	}{
+		{"lazy", func() listAPI { return NewOrderedLazyList[int, int]() }},
+		{"lockfree", func() listAPI { return NewOrderedLockFreeList[int, int]() }},
+	}
+	for _, g := range []int{1, 2, 4, 8, 16, 32, 64} {
+		for _, l := range lists {
+			b.Run(fmt.Sprintf("%s-g%d", l.name, g), func(b *testing.B) {
+				list := l.mk()
+				for i := 0; i < kRange; i += 2 {
+					list.Add(i, i)
+				}
+				b.ResetTimer()
+				var wg sync.WaitGroup
+				for i := 0; i < g; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						r := rand.New(rand.NewSource(int64(i)))
+						for j := i; j < b.N; j += g {
+							k := r.Intn(kRange)
+							switch r.Intn(4) {
+							case 0:
+								list.Add(k, k)
+							case 1:
+								list.Remove(k)
+							default:
+								list.Lookup(k)
+							}
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package gcl
+
+import (
+	"sync/atomic"
+)
+
+//
+// Lock free bounded queues.   Both round the size up to a power of 2 and
+// use free running uint64 indexes, masked into the buffer.   Indexes
+// written by different sides are padded to their own cache lines.
+//
+// SPSCRing is for one producer and one consumer.   Each side owns one
+// index and keeps a cached copy of the other's, so it only reads the
+// other side's cache line when the ring looks full or empty.
+//
+// MPMCRing is Dmitry Vyukov's bounded MPMC queue.   Each cell has a
+// sequence number that says whose turn it is: pos when it is free for the
+// producer at pos, pos+1 when it holds the value for the consumer at pos.
+// Producers and consumers claim positions with a CAS on their index.
+//
+
+func ringSize(size int) uint64 {
+	MustCheck(size > 0, "ring buffer size must be positive")
+	n := uint64(1)
+	for n < uint64(size) {
+		n <<= 1
+	}
+	return n
+}
+
+type SPSCRing[T any] struct {
+	_ [cacheLineSize]byte
+	// consumer side, next to take.
+	head       atomic.Uint64
+	cachedTail uint64
+	_          [cacheLineSize - 16]byte
+	// producer side, next to put.
+	tail       atomic.Uint64
+	cachedHead uint64
+	_          [cacheLineSize - 16]byte
+	mask       uint64
+	buf        []T
+}
+
+// NewSPSCRing creates a single producer single consumer ring of at least
+// size values.
+func NewSPSCRing[T any](size int) *SPSCRing[T] {
+	n := ringSize(size)
+	return &SPSCRing[T]{mask: n - 1, buf: make([]T, n)}
+}
+
+// TryPut puts v, it returns false if the ring is full.   Only the
+// producer may call it.
+func (r *SPSCRing[T]) TryPut(v T) bool {
+	tail := r.tail.Load()
+	if tail-r.cachedHead > r.mask {
+		r.cachedHead = r.head.Load()
+		if tail-r.cachedHead > r.mask {
+			return false
+		}
+	}
+	r.buf[tail&r.mask] = v
+	r.tail.Store(tail + 1)
+	return true
+}
+
+// TryTake takes the oldest value, it returns false if the ring is empty.
+// Only the consumer may call it.
+func (r *SPSCRing[T]) TryTake() (T, bool) {
+	var zero T
+	head := r.head.Load()
+	if head == r.cachedTail {
+		r.cachedTail = r.tail.Load()
+		if head == r.cachedTail {
+			return zero, false
+		}
+	}
+	v := r.buf[head&r.mask]
+	// do not keep the value alive.
+	r.buf[head&r.mask] = zero
+	r.head.Store(head + 1)
+	return v, true
+}
+
+// Len returns the number of values, it may be stale when returned.
+func (r *SPSCRing[T]) Len() int {
+	head := r.head.Load()
+	return int(r.tail.Load() - head)
+}
+
+// Cap returns the size of the ring.
+func (r *SPSCRing[T]) Cap() int {
+	return len(r.buf)
+}
+
+type mpmcCell[T any] struct {
+	seq atomic.Uint64
+	val T
+}
+
+type MPMCRing[T any] struct {
+	_ [cacheLineSize]byte
+	// next position to put.
+	enq atomic.Uint64
+	_   [cacheLineSize - 8]byte
+	// next position to take.
+	deq   atomic.Uint64
+	_     [cacheLineSize - 8]byte
+	mask  uint64
+	cells []mpmcCell[T]
+}
+
+// NewMPMCRing creates a multi producer multi consumer ring of at least
+// size values.
+func NewMPMCRing[T any](size int) *MPMCRing[T] {
+	n := ringSize(size)
+	r := &MPMCRing[T]{mask: n - 1, cells: make([]mpmcCell[T], n)}
+	for i := range r.cells {
+		r.cells[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// TryPut puts v, it returns false if the ring is full.
+func (r *MPMCRing[T]) TryPut(v T) bool {
+	pos := r.enq.Load()
+	for {
+		c := &r.cells[pos&r.mask]
+		dif := int64(c.seq.Load() - pos)
+		switch {
+		case dif == 0:
+			if r.enq.CompareAndSwap(pos, pos+1) {
+				c.val = v
+				c.seq.Store(pos + 1)
+				return true
+			}
+			pos = r.enq.Load()
+		case dif < 0:
+			// the cell still holds the value of the last lap.
+			return false
+		default:
+			// another producer took pos.
+			pos = r.enq.Load()
+		}
+	}
+}
+
+// TryTake takes the oldest value, it returns false if the ring is empty.
+func (r *MPMCRing[T]) TryTake() (T, bool) {
+	var zero T
+	pos := r.deq.Load()
+	for {
+		c := &r.cells[pos&r.mask]
+		dif := int64(c.seq.Load() - (pos + 1))
+		switch {
+		case dif == 0:
+			if r.deq.CompareAndSwap(pos, pos+1) {
+				v := c.val
+				c.val = zero
+				// free for the producer of the next lap.
+				c.seq.Store(pos + r.mask + 1)
+				return v, true
+			}
+			pos = r.deq.Load()
+		case dif < 0:
+			// not put yet.
+			return zero, false
+		default:
+			// another consumer took pos.
+			pos = r.deq.Load()
+		}
+	}
+}
+
+// Len returns the number of values, it may be stale when returned.
+func (r *MPMCRing[T]) Len() int {
+	deq := r.deq.Load()
+	enq := r.enq.Load()
+	if enq < deq {
+		return 0
+	}
+	return int(enq - deq)
+}
+
+// Cap returns the size of the ring.
+func (r *MPMCRing[T]) Cap() int {
+	return len(r.cells)
+}
@@ -0,0 +1,187 @@
+package gcl
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// ringAPI is the common api of SPSCRing and MPMCRing.
+type ringAPI interface {
+	TryPut(v int) bool
+	TryTake() (int, bool)
+}
+
+func ringPut(r ringAPI, v int) {
+	for !r.TryPut(v) {
+		runtime.Gosched()
+	}
+}
+
+func ringTake(r ringAPI) int {
+	for {
+		if v, ok := r.TryTake(); ok {
+			return v
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestSPSCRing(t *testing.T) {
+	const cnt = 100000
+	r := NewSPSCRing[int](100)
+	if r.Cap() != 128 {
+		t.Errorf("cap %d", r.Cap())
+	}
+	go func() {
+		for i := 0; i < cnt; i++ {
+			ringPut(r, i)
+		}
+	}()
+	for i := 0; i < cnt; i++ {
+		if v := ringTake(r); v != i {
+			t.Fatalf("took %d, want %d", v, i)
+		}
+	}
+	if _, ok := r.TryTake(); ok || r.Len() != 0 {
+		t.Errorf("ring not empty")
+	}
+}
+
+func TestMPMCRing(t *testing.T) {
+	const (
+		thCnt   = 4
+		loopCnt = 10000
+	)
+	r := NewMPMCRing[int](16)
+	for i := 0; i < r.Cap(); i++ {
+		r.TryPut(i)
+	}
+	if r.TryPut(0) || r.Len() != 16 {
+		t.Errorf("put into a full ring")
+	}
+	for i := 0; i < r.Cap(); i++ {
+		if v, ok := r.TryTake(); !ok || v != i {
+			t.Fatalf("took %d, want %d", v, i)
+		}
+	}
+
+	var wg sync.WaitGroup
+	seen := make([][]int, thCnt)
+	for i := 0; i < thCnt; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				ringPut(r, i*loopCnt+j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				seen[i] = append(seen[i], ringTake(r))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// each value once, and in order per producer for each consumer.
+	taken := make([]bool, thCnt*loopCnt)
+	for _, vs := range seen {
+		last := make([]int, thCnt)
+		for _, v := range vs {
+			if taken[v] {
+				t.Fatalf("value %d taken twice", v)
+			}
+			taken[v] = true
+			if p := v / loopCnt; v < last[p] {
+				t.Fatalf("value %d after %d", v, last[p])
+			} else {
+				last[p] = v
+			}
+		}
+	}
+	for v, ok := range taken {
+		if !ok {
+			t.Fatalf("value %d lost", v)
+		}
+	}
+}
+
+// chanRing is a buffered channel as a ring.
+type chanRing chan int
+
+func (c chanRing) TryPut(v int) bool {
+	select {
+	case c <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c chanRing) TryTake() (int, bool) {
+	select {
+	case v := <-c:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// BenchmarkRing passes values from producers to consumers, each op is one
+// value.
+func BenchmarkRing(b *testing.B) {
+	const size = 1024
+	rings := []struct {
+		name  string
+		mk    func() ringAPI
+		pairs []int
+	}{
+		{"spsc", func() ringAPI { return NewSPSCRing[int](size) }, []int{1}},
+		{"mpmc", func() ringAPI { return NewMPMCRing[int](size) }, []int{1, 4}},
+		{"chan", func() ringAPI { return make(chanRing, size) }, []int{1, 4}},
+	}
+	for _, rg := range rings {
+		for _, pairs := range rg.pairs {
+			b.Run(fmt.Sprintf("%s-%d", rg.name, pairs), func(b *testing.B) {
+				r := rg.mk()
+				var wg sync.WaitGroup
+				b.ResetTimer()
+				for i := 0; i < pairs; i++ {
+					n := b.N / pairs
+					if i == 0 {
+						n += b.N % pairs
+					}
+					wg.Add(2)
+					go func() {
+						defer wg.Done()
+						for j := 0; j < n; j++ {
+							ringPut(r, j)
+						}
+					}()
+					go func() {
+						defer wg.Done()
+						for j := 0; j < n; j++ {
+							ringTake(r)
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	}
+	// a channel with blocking send and receive, as usually used.
+	b.Run("chan-blocking-1", func(b *testing.B) {
+		c := make(chan int, size)
+		go func() {
+			for i := 0; i < b.N; i++ {
+				c <- i
+			}
+		}()
+		for i := 0; i < b.N; i++ {
+			<-c
+		}
+	})
+}
@@ -0,0 +1,260 @@
+package gcl
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+//
+// lock free skiplist, see the LockFreeSkipList described in
+// The Art of Multiprocessor Programming, Chapter 14
+//
+// Java's AtomicMarkableReference is a (reference, mark) pair that is
+// compared by value.   Each node carries its own two pairs, (node, false)
+// and (node, true), and next pointers point to one of them, so comparing
+// the pointers compares the pairs by value, without allocating a pair on
+// every CAS.
+//
+
+type lfRef[K any, V any] struct {
+	node   *LfSkNode[K, V]
+	marked bool
+}
+
+type LfSkNode[K any, V any] struct {
+	key   K
+	val   V
+	topLv int
+	refs  [2]lfRef[K, V]
+	next  []atomic.Pointer[lfRef[K, V]]
+}
+
+func (n *LfSkNode[K, V]) GetK() K {
+	return n.key
+}
+func (n *LfSkNode[K, V]) GetV() V {
+	return n.val
+}
+
+// ref returns the (n, marked) pair.
+func (n *LfSkNode[K, V]) ref(marked bool) *lfRef[K, V] {
+	if marked {
+		return &n.refs[1]
+	}
+	return &n.refs[0]
+}
+
+func newLfNode[K any, V any](k K, v V, lv int) *LfSkNode[K, V] {
+	n := &LfSkNode[K, V]{
+		key: k, val: v, topLv: lv,
+		next: make([]atomic.Pointer[lfRef[K, V]], lv+1),
+	}
+	n.refs[0].node = n
+	n.refs[1] = lfRef[K, V]{node: n, marked: true}
+	return n
+}
+
+type LockFreeSkiplist[K any, V any] struct {
+	head, tail *LfSkNode[K, V]
+	cmp        func(a, b K) int
+	levelGen
+}
+
+// NewLockFreeSkiplist creates a lock free skiplist, it takes the same
+// options as NewSkipList.
+func NewLockFreeSkiplist[K any, V any](less, eq func(a, b K) bool, opts ...SkiplistOption) *LockFreeSkiplist[K, V] {
+	return NewLockFreeSkiplistFunc[K, V](cmpOf(less, eq), opts...)
+}
+
+// NewLockFreeSkiplistFunc creates a lock free skiplist ordered by the
+// three-way comparator cmp.
+func NewLockFreeSkiplistFunc[K any, V any](cmp func(a, b K) int, opts ...SkiplistOption) *LockFreeSkiplist[K, V] {
+	cfg := newSkiplistConfig(opts)
+	var sl LockFreeSkiplist[K, V]
+	cfg.init(&sl.levelGen)
+
+	var zk K
+	var zv V
+	sl.head = newLfNode(zk, zv, sl.maxLv)
+	sl.tail = newLfNode(zk, zv, sl.maxLv)
+	for i := range sl.head.next {
+		sl.head.next[i].Store(sl.tail.ref(false))
+		sl.tail.next[i].Store(sl.tail.ref(false))
+	}
+	sl.cmp = cmp
+	return &sl
+}
+
+// find fills preds and succs, snipping marked nodes on the way.   It
+// returns if k is found at the bottom level.
+func (sl *LockFreeSkiplist[K, V]) find(k K, preds, succs *[maxNumLevel]*LfSkNode[K, V]) bool {
+retry:
+	for {
+		pred := sl.head
+		var curr *LfSkNode[K, V]
+		c := 1
+		for lv := sl.maxLv; lv >= 0; lv-- {
+			curr = pred.next[lv].Load().node
+			for curr != sl.tail {
+				succ := curr.next[lv].Load()
+				for succ.marked {
+					// curr is being removed, unlink it at this level.
+					if !pred.next[lv].CompareAndSwap(curr.ref(false), succ.node.ref(false)) {
+						continue retry
+					}
+					curr = succ.node
+					if curr == sl.tail {
+						break
+					}
+					succ = curr.next[lv].Load()
+				}
+				if curr == sl.tail {
+					break
+				}
+				if c = sl.cmp(curr.key, k); c >= 0 {
+					break
+				}
+				pred = curr
+				curr = succ.node
+			}
+			preds[lv] = pred
+			succs[lv] = curr
+		}
+		return curr != sl.tail && c == 0
+	}
+}
+
+// Add key, val to the list.  Return true if added, false if already exists
+func (sl *LockFreeSkiplist[K, V]) Add(k K, v V) bool {
+	topLv := sl.randomLv()
+	var preds [maxNumLevel]*LfSkNode[K, V]
+	var succs [maxNumLevel]*LfSkNode[K, V]
+	for {
+		if sl.find(k, &preds, &succs) {
+			return false
+		}
+
+		nn := newLfNode(k, v, topLv)
+		for lv := 0; lv <= topLv; lv++ {
+			nn.next[lv].Store(succs[lv].ref(false))
+		}
+		// linking the bottom level is the linearization point.
+		if !preds[0].next[0].CompareAndSwap(succs[0].ref(false), nn.ref(false)) {
+			continue
+		}
+
+		for lv := 1; lv <= topLv; lv++ {
+			for {
+				// succs may have changed since nn was created, repoint nn
+				// before linking it, unless a remove has already marked it.
+				nx := nn.next[lv].Load()
+				if nx.marked {
+					return true
+				}
+				if nx.node != succs[lv] && !nn.next[lv].CompareAndSwap(nx, succs[lv].ref(false)) {
+					continue
+				}
+				if preds[lv].next[lv].CompareAndSwap(succs[lv].ref(false), nn.ref(false)) {
+					break
+				}
+				sl.find(k, &preds, &succs)
+			}
+		}
+		return true
+	}
+}
+
+// Remove key from the list.  Return true if removed, false if not found
+func (sl *LockFreeSkiplist[K, V]) Remove(k K) bool {
+	var preds [maxNumLevel]*LfSkNode[K, V]
+	var succs [maxNumLevel]*LfSkNode[K, V]
+	if !sl.find(k, &preds, &succs) {
+		return false
+	}
+
+	victim := succs[0]
+	// mark upper levels, top down.
+	for lv := victim.topLv; lv >= 1; lv-- {
+		succ := victim.next[lv].Load()
+		for !succ.marked {
+			victim.next[lv].CompareAndSwap(succ, succ.node.ref(true))
+			succ = victim.next[lv].Load()
+		}
+	}
+
+	// marking the bottom level is the linearization point, whoever marks
+	// it removes the key.
+	succ := victim.next[0].Load()
+	for {
+		if succ.marked {
+			return false
+		}
+		if victim.next[0].CompareAndSwap(succ, succ.node.ref(true)) {
+			// physically remove
+			sl.find(k, &preds, &succs)
+			return true
+		}
+		succ = victim.next[0].Load()
+	}
+}
+
+// Lookup is wait free, it skips marked nodes without unlinking them.
+func (sl *LockFreeSkiplist[K, V]) Lookup(k K) (V, bool) {
+	pred := sl.head
+	var curr *LfSkNode[K, V]
+	c := 1
+	for lv := sl.maxLv; lv >= 0; lv-- {
+		curr = pred.next[lv].Load().node
+		for curr != sl.tail {
+			succ := curr.next[lv].Load()
+			for succ.marked && succ.node != sl.tail {
+				curr = succ.node
+				succ = curr.next[lv].Load()
+			}
+			if succ.marked {
+				curr = sl.tail
+				break
+			}
+			if c = sl.cmp(curr.key, k); c >= 0 {
+				break
+			}
+			pred = curr
+			curr = succ.node
+		}
+	}
+
+	if curr != sl.tail && c == 0 {
+		return curr.val, true
+	}
+	return sl.head.val, false
+}
+
+func (sl *LockFreeSkiplist[K, V]) Next(curr *LfSkNode[K, V]) *LfSkNode[K, V] {
+	if curr == nil {
+		curr = sl.head
+	}
+
+	next := curr.next[0].Load().node
+	for next != sl.tail {
+		if !next.next[0].Load().marked {
+			return next
+		}
+		next = next.next[0].Load().node
+	}
+	return nil
+}
+
+func (sl *LockFreeSkiplist[K, V]) First() *LfSkNode[K, V] {
+	return sl.Next(nil)
+}
+
+// All iterates the key value pairs in order, see Next.
+func (sl *LockFreeSkiplist[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := sl.First(); n != nil; n = sl.Next(n) {
+			if !yield(n.key, n.val) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gcl
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestLockFreeSkipList(t *testing.T) {
+	// increase the following to stress
+	const (
+		loopCnt = 1000
+		thCnt   = 10
+		kRange  = 1000
+	)
+
+	list := NewLockFreeSkiplist[int64, int64](
+		func(a, b int64) bool { return a < b },
+		func(a, b int64) bool { return a == b },
+	)
+
+	var insCnt, remCnt [thCnt]int
+	var wg sync.WaitGroup
+	for i := 0; i < thCnt; i++ {
+		wg.Add(1)
+		go func(ii int) {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				ikey := rand.Int63() % kRange
+				if list.Add(ikey, int64(ii)) {
+					insCnt[ii]++
+				}
+				ival, ok := list.Lookup(ikey)
+				if ok && ival != int64(ii) && list.Remove(ikey) {
+					remCnt[ii]++
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	cnt := 0
+	var prev int64 = -1
+	for n := list.First(); n != nil; n = list.Next(n) {
+		if n.GetK() <= prev {
+			t.Fatalf("keys out of order, %d after %d", n.GetK(), prev)
+		}
+		prev = n.GetK()
+		cnt++
+	}
+
+	for i := 0; i < thCnt; i++ {
+		cnt += remCnt[i] - insCnt[i]
+	}
+	if cnt != 0 {
+		t.Errorf("counting error, off by %d", cnt)
+	}
+}
+
+// skiplistAPI is the common api of Skiplist and LockFreeSkiplist.
+type skiplistAPI interface {
+	Add(k, v int64) bool
+	Remove(k int64) bool
+	Lookup(k int64) (int64, bool)
+}
+
+func benchSkiplists() []struct {
+	name string
+	mk   func() skiplistAPI
+} {
+	less := func(a, b int64) bool { return a < b }
+	eq := func(a, b int64) bool { return a == b }
+	return []struct {
+		name string
+		mk   func() skiplistAPI
+	}{
+		{"lazy", func() skiplistAPI { return NewSkipList[int64, int64](less, eq, WithMaxLevel(16)) }},
+		{"lockfree", func() skiplistAPI { return NewLockFreeSkiplist[int64, int64](less, eq, WithMaxLevel(16)) }},
+	}
+}
+
+// BenchmarkSkiplistCompare runs the lazy and the lock free skiplist on
+// the same workloads, writePct is the percentage of Add/Remove.
+func BenchmarkSkiplistCompare(b *testing.B) {
+	const kRange = 100000
+	for _, writePct := range []int{0, 20, 50, 100} {
+		for _, sl := range benchSkiplists() {
+			b.Run(fmt.Sprintf("%s-write%d", sl.name, writePct), func(b *testing.B) {
+				list := sl.mk()
+				for i := int64(0); i < kRange; i += 2 {
+					list.Add(i, i)
+				}
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					r := rand.New(rand.NewSource(rand.Int63()))
+					for pb.Next() {
+						k := r.Int63n(kRange)
+						op := r.Intn(100)
+						switch {
+						case op >= writePct:
+							list.Lookup(k)
+						case op%2 == 0:
+							list.Add(k, k)
+						default:
+							list.Remove(k)
+						}
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package gcl
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//
+// Linearizability checker and stress harness for the concurrent ordered
+// containers.  Each worker runs random Add/Put/Remove/Lookup/scan ops and
+// records call and return timestamps from a shared logical clock.   The
+// history is then checked against a sequential ordered map.
+//
+// Point ops on different keys commute, so the history is split per key
+// and each part is checked with the Wing-Gong search, memoized on (set of
+// linearized ops, model state) as in Lowe's and Porcupine's variants.
+//
+// Scans are weakly consistent in every container, so they are checked
+// for order, for not returning keys that were never added, and for not
+// missing keys that were present for the whole scan.
+//
+// Run with -race for the data race checks as well.
+//
+// Containers that are not maps are not targets:
+//
+//   - Multimap keeps duplicates, so it is not the model's map.   Its
+//     entries are keys of a Skiplist or LazyList, both targets.
+//   - SkipPriorityQueue and the rings, SPSCRing, MPMCRing, BlockingRing,
+//     ByteRing and MmapRing, are queues.   Their tests check that each
+//     value is taken once, none is lost, and each producer's values come
+//     in order.
+//
+
+type linOpKind int
+
+const (
+	linAdd linOpKind = iota
+	linPut
+	linRemove
+	linLookup
+	linScan
+)
+
+type linOp struct {
+	kind      linOpKind
+	key, val  int
+	ok        bool
+	rval      int
+	keys      []int
+	call, ret int64
+}
+
+// linTarget adapts a container to the harness.   put is optional.
+type linTarget struct {
+	name   string
+	add    func(k, v int) bool
+	remove func(k int) bool
+	lookup func(k int) (int, bool)
+	scan   func() []int
+	put    func(k, v int)
+}
+
+// linTargets returns makers of the targets, resources they need are
+// released at the end of t.
+func linTargets(t *testing.T) []func() linTarget {
+	less := func(a, b int) bool { return a < b }
+	eq := func(a, b int) bool { return a == b }
+	return []func() linTarget{
+		func() linTarget {
+			l := NewLazyList[int, int](less, eq)
+			return linTarget{"LazyList", l.Add, l.Remove, l.Lookup, func() []int {
+				var keys []int
+				for it := l.Iterator(math.MinInt, math.MaxInt); it != nil; it = l.Next(it) {
+					keys = append(keys, it.GetKey())
+				}
+				return keys
+			}, func(k, v int) { l.Put(k, v) }}
+		},
+		func() linTarget {
+			l := NewSkipList[int, int](less, eq, WithMaxLevel(4))
+			return linTarget{"Skiplist", l.Add, l.Remove, l.Lookup, func() []int {
+				var keys []int
+				for n := l.First(); n != nil; n = l.Next(n) {
+					keys = append(keys, n.GetK())
+				}
+				return keys
+			}, func(k, v int) { l.Put(k, v) }}
+		},
+		func() linTarget {
+			l := NewLockFreeSkiplist[int, int](less, eq, WithMaxLevel(4))
+			return linTarget{"LockFreeSkiplist", l.Add, l.Remove, l.Lookup, func() []int {
+				var keys []int
+				for n := l.First(); n != nil; n = l.Next(n) {
+					keys = append(keys, n.GetK())
+				}
+				return keys
+			}, nil}
+		},
+		func() linTarget {
+			l := NewLockFreeList[int, int](less, eq)
+			return linTarget{"LockFreeList", l.Add, l.Remove, l.Lookup, func() []int {
+				var keys []int
+				for it := l.Iterator(math.MinInt, math.MaxInt); it != nil; it = l.Next(it) {
+					keys = append(keys, it.GetKey())
+				}
+				return keys
+			}, nil}
+		},
+		func() linTarget {
+			l := NewOrderedLazyList[int, int](WithListReclamation())
+			return linTarget{"LazyListReclaim", l.Add, l.Remove, l.Lookup, func() []int {
+				defer l.Pin().Unpin()
+				var keys []int
+				for it := l.Iterator(math.MinInt, math.MaxInt); it != nil; it = l.Next(it) {
+					keys = append(keys, it.GetKey())
+				}
+				return keys
+			}, func(k, v int) { l.Put(k, v) }}
+		},
+		func() linTarget {
+			l := NewOrderedSkipList[int, int](WithMaxLevel(4), WithReclamation())
+			return linTarget{"SkiplistReclaim", l.Add, l.Remove, l.Lookup, func() []int {
+				var keys []int
+				for k := range l.All() {
+					keys = append(keys, k)
+				}
+				return keys
+			}, func(k, v int) { l.Put(k, v) }}
+		},
+		func() linTarget {
+			l := NewMVSkiplist[int, int](less, eq, WithMaxLevel(4))
+			return linTarget{"MVSkiplist", l.Add, l.Remove, l.Lookup, func() []int {
+				var keys []int
+				for k := range l.All() {
+					keys = append(keys, k)
+				}
+				return keys
+			}, l.Put}
+		},
+		func() linTarget {
+			m := NewConcurrentMap[int, int](IntHash[int], 4)
+			return linTarget{"ConcurrentMap",
+				func(k, v int) bool {
+					_, loaded := m.LoadOrStore(k, v)
+					return !loaded
+				},
+				func(k int) bool {
+					_, ok := m.LoadAndDelete(k)
+					return ok
+				},
+				m.Load,
+				func() []int {
+					// Range is in hash order.
+					var keys []int
+					m.Range(func(k, _ int) bool {
+						keys = append(keys, k)
+						return true
+					})
+					slices.Sort(keys)
+					return keys
+				},
+				m.Store,
+			}
+		},
+		func() linTarget {
+			l := NewTTLSkiplist[int, int](less, eq, nil, WithTTLSkiplistOptions(WithMaxLevel(4)))
+			return linTarget{"TTLSkiplist",
+				func(k, v int) bool { return l.Add(k, v, time.Hour) },
+				l.Remove,
+				l.Lookup,
+				func() []int {
+					var keys []int
+					for k := range l.All() {
+						keys = append(keys, k)
+					}
+					return keys
+				},
+				func(k, v int) { l.Put(k, v, time.Hour) },
+			}
+		},
+		func() linTarget {
+			d, err := OpenDurableSkiplist(t.TempDir(), cmp.Compare[int], IntCodec[int]{}, IntCodec[int]{},
+				WithSyncPolicy(SyncNone), WithSkiplistOptions(WithMaxLevel(4)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { d.Close() })
+			return linTarget{"DurableSkiplist",
+				func(k, v int) bool {
+					ok, err := d.Add(k, v)
+					if err != nil {
+						t.Error(err)
+					}
+					return ok
+				},
+				func(k int) bool {
+					ok, err := d.Remove(k)
+					if err != nil {
+						t.Error(err)
+					}
+					return ok
+				},
+				d.Lookup,
+				func() []int {
+					var keys []int
+					for k := range d.All() {
+						keys = append(keys, k)
+					}
+					return keys
+				},
+				func(k, v int) {
+					if _, err := d.Put(k, v); err != nil {
+						t.Error(err)
+					}
+				},
+			}
+		},
+	}
+}
+
+// linStress runs a random workload on target and returns the history.
+func linStress(target linTarget, thCnt, opCnt, kRange int, seed int64) []linOp {
+	var clock atomic.Int64
+	hists := make([][]linOp, thCnt)
+	var wg sync.WaitGroup
+	for th := 0; th < thCnt; th++ {
+		wg.Add(1)
+		go func(th int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed + int64(th)))
+			for i := 0; i < opCnt; i++ {
+				// values are unique, so lookups tell which add they saw.
+				op := linOp{key: r.Intn(kRange), val: th*opCnt + i + 1}
+				op.call = clock.Add(1)
+				switch x := r.Intn(100); {
+				case x < 10 && target.put != nil:
+					op.kind = linPut
+					target.put(op.key, op.val)
+				case x < 35:
+					op.kind = linAdd
+					op.ok = target.add(op.key, op.val)
+				case x < 65:
+					op.kind = linRemove
+					op.ok = target.remove(op.key)
+				case x < 95:
+					op.kind = linLookup
+					op.rval, op.ok = target.lookup(op.key)
+				default:
+					op.kind = linScan
+					op.keys = target.scan()
+				}
+				op.ret = clock.Add(1)
+				hists[th] = append(hists[th], op)
+			}
+		}(th)
+	}
+	wg.Wait()
+	return slices.Concat(hists...)
+}
+
+type linState struct {
+	present bool
+	val     int
+}
+
+// linStep applies op to the sequential model, returns false if the
+// result of op is impossible in state st.
+func linStep(st linState, op *linOp) (linState, bool) {
+	switch op.kind {
+	case linAdd:
+		if op.ok {
+			return linState{true, op.val}, !st.present
+		}
+		return st, st.present
+	case linPut:
+		return linState{true, op.val}, true
+	case linRemove:
+		if op.ok {
+			return linState{}, st.present
+		}
+		return st, !st.present
+	default:
+		if op.ok {
+			return st, st.present && st.val == op.rval
+		}
+		return st, !st.present
+	}
+}
+
+// linearizable checks the history of a single key, starting from absent.
+func linearizable(ops []linOp) bool {
+	done := make([]uint64, (len(ops)+63)/64)
+	seen := make(map[string]bool)
+	memoKey := func(st linState) string {
+		b := binary.LittleEndian.AppendUint64(nil, uint64(st.val))
+		if st.present {
+			b = append(b, 1)
+		}
+		for _, w := range done {
+			b = binary.LittleEndian.AppendUint64(b, w)
+		}
+		return string(b)
+	}
+	isDone := func(i int) bool { return done[i/64]&(1<<(i%64)) != 0 }
+
+	var search func(left int, st linState) bool
+	search = func(left int, st linState) bool {
+		if left == 0 {
+			return true
+		}
+		mk := memoKey(st)
+		if seen[mk] {
+			return false
+		}
+		seen[mk] = true
+
+		// an op can go next only if it was called before every pending
+		// op returned.
+		minRet := int64(math.MaxInt64)
+		for i := range ops {
+			if !isDone(i) {
+				minRet = min(minRet, ops[i].ret)
+			}
+		}
+		for i := range ops {
+			if isDone(i) || ops[i].call > minRet {
+				continue
+			}
+			if nst, ok := linStep(st, &ops[i]); ok {
+				done[i/64] |= 1 << (i % 64)
+				if search(left-1, nst) {
+					return true
+				}
+				done[i/64] &^= 1 << (i % 64)
+			}
+		}
+		return false
+	}
+	return search(len(ops), linState{})
+}
+
+// checkScan checks a scan against the point ops of the history.
+func checkScan(scan *linOp, perKey map[int][]linOp) error {
+	if !slices.IsSorted(scan.keys) || len(slices.Compact(slices.Clone(scan.keys))) != len(scan.keys) {
+		return fmt.Errorf("scan keys not strictly ascending: %v", scan.keys)
+	}
+	for _, k := range scan.keys {
+		added := false
+		for _, op := range perKey[k] {
+			added = added || (op.inserts() && op.call < scan.ret)
+		}
+		if !added {
+			return fmt.Errorf("scan returned %d that was never added", k)
+		}
+	}
+
+	for k, ops := range perKey {
+		present := false
+		// present for the whole scan: a successful add returned before the
+		// scan started, and no successful remove could come after it.
+		for i := range ops {
+			a := &ops[i]
+			if !a.inserts() || a.ret > scan.call {
+				continue
+			}
+			removed := false
+			for j := range ops {
+				r := &ops[j]
+				if r.kind == linRemove && r.ok && r.call < scan.ret && r.ret > a.call {
+					removed = true
+				}
+			}
+			present = present || !removed
+		}
+
+		if present && !slices.Contains(scan.keys, k) {
+			return fmt.Errorf("scan missed %d", k)
+		}
+	}
+	return nil
+}
+
+// inserts returns if op may have inserted its key.
+func (op *linOp) inserts() bool {
+	return op.kind == linPut || (op.kind == linAdd && op.ok)
+}
+
+func checkHistory(hist []linOp) error {
+	perKey := make(map[int][]linOp)
+	var scans []*linOp
+	for i := range hist {
+		if hist[i].kind == linScan {
+			scans = append(scans, &hist[i])
+		} else {
+			perKey[hist[i].key] = append(perKey[hist[i].key], hist[i])
+		}
+	}
+	for k, ops := range perKey {
+		if !linearizable(ops) {
+			return fmt.Errorf("history of key %d is not linearizable", k)
+		}
+	}
+	for _, scan := range scans {
+		if err := checkScan(scan, perKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLinCheckRejects(t *testing.T) {
+	// lookup returns a value that a later remove had already removed.
+	hist := []linOp{
+		{kind: linAdd, key: 1, val: 1, ok: true, call: 1, ret: 2},
+		{kind: linRemove, key: 1, ok: true, call: 3, ret: 4},
+		{kind: linLookup, key: 1, ok: true, rval: 1, call: 5, ret: 6},
+	}
+	if checkHistory(hist) == nil {
+		t.Errorf("stale lookup should not be linearizable")
+	}
+	// overlapping with the remove, it is fine.
+	hist[2].call = 3
+	if err := checkHistory(hist); err != nil {
+		t.Errorf("concurrent lookup should be linearizable: %v", err)
+	}
+
+	// a lookup after a put returned sees the put value.
+	put := []linOp{
+		{kind: linAdd, key: 1, val: 1, ok: true, call: 1, ret: 2},
+		{kind: linPut, key: 1, val: 2, call: 3, ret: 4},
+		{kind: linLookup, key: 1, ok: true, rval: 1, call: 5, ret: 6},
+	}
+	if checkHistory(put) == nil {
+		t.Errorf("lookup missed a put")
+	}
+
+	hist = append(hist, linOp{kind: linScan, call: 1, ret: 2, keys: []int{2}})
+	if checkHistory(hist) == nil {
+		t.Errorf("scan returned a key never added")
+	}
+}
+
+func TestLinearizable(t *testing.T) {
+	const (
+		rounds = 10
+		thCnt  = 8
+		opCnt  = 200
+		kRange = 8
+	)
+	for _, mk := range linTargets(t) {
+		t.Run(mk().name, func(t *testing.T) {
+			for r := 0; r < rounds; r++ {
+				hist := linStress(mk(), thCnt, opCnt, kRange, int64(r*thCnt))
+				if err := checkHistory(hist); err != nil {
+					t.Fatalf("round %d: %v", r, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLinCheckCatchesRace(t *testing.T) {
+	// add checks then sets with a yield in between, so two adds of the
+	// same key can both succeed.
+	var m sync.Map
+	racy := linTarget{
+		name: "racy",
+		add: func(k, v int) bool {
+			if _, ok := m.Load(k); ok {
+				return false
+			}
+			runtime.Gosched()
+			m.Store(k, v)
+			return true
+		},
+		remove: func(k int) bool {
+			_, ok := m.LoadAndDelete(k)
+			return ok
+		},
+		lookup: func(k int) (int, bool) {
+			v, ok := m.Load(k)
+			if !ok {
+				return 0, false
+			}
+			return v.(int), true
+		},
+		scan: func() []int { return nil },
+	}
+	for r := 0; r < 10; r++ {
+		hist := linStress(racy, 4, 200, 4, int64(r))
+		// scan is not implemented.
+		hist = slices.DeleteFunc(hist, func(op linOp) bool { return op.kind == linScan })
+		if checkHistory(hist) != nil {
+			return
+		}
+	}
+	t.Errorf("racy add was not detected")
+}
@@ -0,0 +1,363 @@
+//go:build linux
+
+package gcl
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+//
+// MmapRing is a ring of records in a memory mapped file, which outlives
+// the process that writes it, as a flight recorder.   When full, an
+// append overwrites the oldest records.   The file is
+//
+//	header, 64 bytes:
+//		[8]byte magic, uint32 version, uint32 unused
+//		uint64 size of the data area
+//		uint64 head, offset of the oldest record
+//		uint64 tail, offset after the newest record
+//		uint64 read, offset committed by a reader
+//	data area of size bytes
+//
+// Offsets are logical, they only grow, and a record at off is at
+// off % size in the data area.   A record is
+//
+//	uint32 payload length, uint32 crc32c of payload, payload
+//
+// padded to 8 bytes.   Records do not wrap around, when a record does
+// not fit before the end of the data area, a padding marker, length
+// 0xffffffff, says to skip to the start.   Header words and the words of
+// a record are in native byte order, the file is not portable across
+// architectures, and are stored and loaded atomically.
+//
+// One process appends, it opens the ring with OpenMmapRing, which locks
+// the file, others open it with TailMmapRing.   The appender stores the
+// new head before it overwrites any record, writes the record, payload,
+// crc then length, then stores the new tail.   Readers, in any process,
+// load the length and crc of a record before tail, copy the payload and
+// check it against the crc, then load head again.   If head has passed
+// the record, it may have been overwritten while copied, and the reader
+// skips to head.
+//
+// The copy is made with plain loads, which on weakly ordered CPUs may see
+// a newer record's bytes before the appender's new head is visible, so
+// the crc, not only head, says if the copy is whole.   The length and crc
+// are loaded atomically, if they are of a newer record, the new head is
+// visible too.   A copy that does not match its crc while head has not
+// moved is read again a few times before it is an error.
+//
+// When the appender opens the ring, records from head are checked and
+// the tail is cut at the first bad one, as one torn by a crash.   Offsets
+// in the header are checked, so a damaged file is an error, or loses
+// records, but does not crash the process opening it.
+//
+
+const (
+	mrMagic   = "gclmring"
+	mrVersion = 1
+	mrHdrSize = 64
+	mrPad     = math.MaxUint32
+	// reads of a record not matching its crc before it is an error.
+	mrRetries = 16
+)
+
+type MmapRing struct {
+	f    *os.File
+	mem  []byte
+	data []byte
+	size uint64
+	// in the header.
+	head, tail, read *atomic.Uint64
+	// opened by TailMmapRing.
+	tailing bool
+	// serializes appends.
+	mu sync.Mutex
+}
+
+// OpenMmapRing opens the ring in the file path to append, or creates it
+// with a data area of size bytes, rounded up to 8.   The size of an
+// existing ring is kept.   It fails if another process has it open to
+// append.
+func OpenMmapRing(path string, size int) (*MmapRing, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("mmap ring %s: lock: %w", path, err)
+	}
+	m, err := openMmapRing(f, size, false)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("mmap ring %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// TailMmapRing opens the existing ring in the file path to read, while a
+// process may append to it.
+func TailMmapRing(path string) (*MmapRing, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	m, err := openMmapRing(f, 0, true)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("mmap ring %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func openMmapRing(f *os.File, size int, tailing bool) (*MmapRing, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	flen := st.Size()
+	if flen == 0 && !tailing {
+		if size < 16 {
+			return nil, fmt.Errorf("size %d too small", size)
+		}
+		flen = int64(mrHdrSize + (size+7)&^7)
+		if err := f.Truncate(flen); err != nil {
+			return nil, err
+		}
+	} else if flen < mrHdrSize+16 || flen%8 != 0 {
+		return nil, fmt.Errorf("bad file size %d", flen)
+	}
+
+	mem, err := syscall.Mmap(int(f.Fd()), 0, int(flen),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	m := &MmapRing{
+		f:    f,
+		mem:  mem,
+		data: mem[mrHdrSize:],
+		size: uint64(len(mem) - mrHdrSize),
+		head: (*atomic.Uint64)(unsafe.Pointer(&mem[24])),
+		tail: (*atomic.Uint64)(unsafe.Pointer(&mem[32])),
+		read: (*atomic.Uint64)(unsafe.Pointer(&mem[40])),
+
+		tailing: tailing,
+	}
+	version := (*atomic.Uint32)(unsafe.Pointer(&mem[8]))
+	sizeWord := (*atomic.Uint64)(unsafe.Pointer(&mem[16]))
+
+	// a crash while creating leaves no magic, the magic is written last.
+	if string(mem[:8]) == string(make([]byte, 8)) && !tailing {
+		version.Store(mrVersion)
+		sizeWord.Store(m.size)
+		m.head.Store(0)
+		m.tail.Store(0)
+		m.read.Store(0)
+		copy(mem, mrMagic)
+	}
+
+	switch {
+	case string(mem[:8]) != mrMagic:
+		err = fmt.Errorf("bad magic %q", mem[:8])
+	case version.Load() != mrVersion:
+		err = fmt.Errorf("unsupported version %d", version.Load())
+	case sizeWord.Load() != m.size:
+		err = fmt.Errorf("size %d, file has %d", sizeWord.Load(), m.size)
+	case m.head.Load()%8 != 0:
+		err = fmt.Errorf("bad head offset %d", m.head.Load())
+	case tailing && (m.tail.Load()%8 != 0 || m.tail.Load() < m.head.Load()):
+		err = fmt.Errorf("bad tail offset %d", m.tail.Load())
+	}
+	if err != nil {
+		syscall.Munmap(mem)
+		return nil, err
+	}
+	if !tailing {
+		m.recover()
+	}
+	return m, nil
+}
+
+// recover cuts the tail at the first bad record.
+func (m *MmapRing) recover() {
+	head, tail := m.head.Load(), m.tail.Load()
+	if tail%8 != 0 || tail < head || tail-head > m.size {
+		tail = head
+	}
+	off := head
+	for off < tail {
+		p, crc, next, err := m.record(off)
+		if err != nil || next > tail || crc32.Checksum(p, crcTable) != crc {
+			break
+		}
+		off = next
+	}
+	m.tail.Store(off)
+	if read := m.read.Load(); read%8 != 0 || read < head || read > off {
+		m.read.Store(head)
+	}
+}
+
+// word returns the word at phys in the data area, which is 4 aligned.
+func (m *MmapRing) word(phys uint64) *atomic.Uint32 {
+	return (*atomic.Uint32)(unsafe.Pointer(&m.data[phys]))
+}
+
+// record returns the payload and crc of the record at off, skipping a
+// padding marker, and the offset after it.   The payload is in the data
+// area, it may be overwritten.
+func (m *MmapRing) record(off uint64) ([]byte, uint32, uint64, error) {
+	phys := off % m.size
+	if phys%8 != 0 || m.size-phys < 8 {
+		return nil, 0, 0, fmt.Errorf("bad record offset %d", off)
+	}
+	n := m.word(phys).Load()
+	if n == mrPad && phys != 0 {
+		off += m.size - phys
+		phys = 0
+		n = m.word(0).Load()
+	}
+	if uint64(n) > m.size-phys-8 {
+		return nil, 0, 0, fmt.Errorf("bad record length %d at offset %d", n, off)
+	}
+	crc := m.word(phys + 4).Load()
+	return m.data[phys+8 : phys+8+uint64(n)], crc, off + 8 + (uint64(n)+7)&^7, nil
+}
+
+// Append appends the record p, overwriting the oldest records if there is
+// no room.   p may be up to the size of the ring less 8 bytes, and less
+// than 4 GiB less 1, the padding marker.
+func (m *MmapRing) Append(p []byte) error {
+	need := 8 + (uint64(len(p))+7)&^7
+	if m.tailing {
+		return errors.New("ring opened to tail")
+	}
+	if need > m.size || uint64(len(p)) >= mrPad {
+		return fmt.Errorf("record of %d bytes too large", len(p))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	head, tail := m.head.Load(), m.tail.Load()
+	var pad uint64
+	if phys := tail % m.size; phys+need > m.size {
+		pad = m.size - phys
+	}
+	end := tail + pad + need
+	for end-head > m.size {
+		if head == tail {
+			// all evicted, the padding too.
+			head = tail + pad
+			break
+		}
+		_, _, next, err := m.record(head)
+		if err != nil {
+			return err
+		}
+		head = next
+	}
+	m.head.Store(head)
+
+	if pad > 0 {
+		m.word(tail % m.size).Store(mrPad)
+	}
+	phys := (tail + pad) % m.size
+	copy(m.data[phys+8:], p)
+	m.word(phys + 4).Store(crc32.Checksum(p, crcTable))
+	m.word(phys).Store(uint32(len(p)))
+	m.tail.Store(end)
+	return nil
+}
+
+// Sync writes the ring to the file.   Without it, the ring survives a
+// crash of the process, but not of the system.
+func (m *MmapRing) Sync() error {
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&m.mem[0])),
+		uintptr(len(m.mem)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Close unmaps and closes the file, which unlocks it.   Readers of the
+// ring must not be used after.
+func (m *MmapRing) Close() error {
+	return errors.Join(syscall.Munmap(m.mem), m.f.Close())
+}
+
+// Reader returns a reader from the committed read offset, or from the
+// oldest record if that is not valid.
+func (m *MmapRing) Reader() *MmapRingReader {
+	off := m.read.Load()
+	if head := m.head.Load(); off%8 != 0 || off < head || off > m.tail.Load() {
+		off = head
+	}
+	return &MmapRingReader{m: m, off: off}
+}
+
+// MmapRingReader reads the records of a ring in order.
+type MmapRingReader struct {
+	m    *MmapRing
+	off  uint64
+	lost uint64
+	buf  []byte
+}
+
+// Next returns the next record, or io.EOF if there is none yet, so that
+// polling Next tails the ring.   The record is valid until the next call.
+// Records overwritten before they are read are skipped.
+func (r *MmapRingReader) Next() ([]byte, error) {
+	m := r.m
+	for tries := 0; ; {
+		if head := m.head.Load(); r.off < head {
+			r.lost += head - r.off
+			r.off = head
+		}
+		if r.off >= m.tail.Load() {
+			return nil, io.EOF
+		}
+		p, crc, next, err := m.record(r.off)
+		if err == nil {
+			r.buf = append(r.buf[:0], p...)
+			if crc32.Checksum(r.buf, crcTable) != crc {
+				err = fmt.Errorf("checksum mismatch at offset %d", r.off)
+			}
+		}
+		if m.head.Load() > r.off {
+			// overwritten while copied.
+			continue
+		}
+		if err == nil {
+			r.off = next
+			return r.buf, nil
+		}
+		// the appender's new head may not be visible yet.
+		if tries++; tries > mrRetries {
+			return nil, err
+		}
+		runtime.Gosched()
+	}
+}
+
+// Commit stores the reader's offset as the committed read offset, where
+// readers start after a restart.
+func (r *MmapRingReader) Commit() {
+	r.m.read.Store(r.off)
+}
+
+// Lost returns the bytes of records overwritten before they were read.
+func (r *MmapRingReader) Lost() uint64 {
+	return r.lost
+}
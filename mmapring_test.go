@@ -0,0 +1,355 @@
+//go:build linux
+
+package gcl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// mrRecord makes record i, 8 bytes of i then some bytes of i.
+func mrRecord(r *rand.Rand, i uint64) []byte {
+	p := binary.BigEndian.AppendUint64(nil, i)
+	return append(p, bytes.Repeat([]byte{byte(i)}, r.Intn(100))...)
+}
+
+// mrCheck checks p is a record made by mrRecord, and returns its i.
+func mrCheck(t *testing.T, p []byte) uint64 {
+	t.Helper()
+	if len(p) < 8 {
+		t.Fatalf("record of %d bytes", len(p))
+	}
+	i := binary.BigEndian.Uint64(p)
+	for _, c := range p[8:] {
+		if c != byte(i) {
+			t.Fatalf("record %d has byte %d", i, c)
+		}
+	}
+	return i
+}
+
+func TestMmapRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	m, err := OpenMmapRing(path, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMmapRing(path, 1000); err == nil {
+		t.Errorf("opened to append twice")
+	}
+	if err := m.Append(make([]byte, 1000)); err == nil {
+		t.Errorf("appended a record larger than the ring")
+	}
+
+	// wrap around a few times, the reader is lapped.
+	r := rand.New(rand.NewSource(1))
+	rd := m.Reader()
+	const cnt = 200
+	for i := uint64(0); i < cnt; i++ {
+		if err := m.Append(mrRecord(r, i)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 10 {
+			p, err := rd.Next()
+			if err != nil || mrCheck(t, p) != 0 {
+				t.Fatalf("first record got %v", err)
+			}
+			rd.Commit()
+		}
+	}
+	last := uint64(0)
+	for {
+		p, err := rd.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if i := mrCheck(t, p); i <= last {
+			t.Fatalf("record %d after %d", i, last)
+		} else {
+			last = i
+		}
+	}
+	if last != cnt-1 || rd.Lost() == 0 {
+		t.Errorf("last record %d, lost %d", last, rd.Lost())
+	}
+	// a record as large as the ring evicts all.
+	if err := m.Append(make([]byte, 992)); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := m.Reader().Next(); err != nil || len(p) != 992 {
+		t.Errorf("whole ring record got %d bytes, %v", len(p), err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMmapRingReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	m, err := OpenMmapRing(path, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(2))
+	var lastOff uint64
+	for i := uint64(0); i < 20; i++ {
+		lastOff = m.tail.Load()
+		m.Append(mrRecord(r, i))
+	}
+	rd := m.Reader()
+	for i := 0; i < 5; i++ {
+		rd.Next()
+	}
+	rd.Commit()
+	m.Close()
+
+	// tear the last record, as a crash while writing it.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff}, mrHdrSize+int64(lastOff)+8)
+	f.Close()
+
+	m, err = OpenMmapRing(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	rd = m.Reader()
+	for want := uint64(5); want < 19; want++ {
+		p, err := rd.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", want, err)
+		}
+		if i := mrCheck(t, p); i != want {
+			t.Fatalf("record %d, want %d", i, want)
+		}
+	}
+	if _, err := rd.Next(); err != io.EOF {
+		t.Errorf("torn record read, %v", err)
+	}
+
+	// bad files.
+	bad := filepath.Join(t.TempDir(), "bad")
+	os.WriteFile(bad, bytes.Repeat([]byte("x"), 256), 0o644)
+	if _, err := OpenMmapRing(bad, 0); err == nil {
+		t.Errorf("opened a file with bad magic")
+	}
+	if _, err := TailMmapRing(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Errorf("tailed a missing ring")
+	}
+}
+
+// TestMmapRingDamaged opens rings with damaged header offsets.
+func TestMmapRingDamaged(t *testing.T) {
+	const size = 4096
+	r := rand.New(rand.NewSource(4))
+	for _, tc := range []struct {
+		name string
+		// header word and value written to it.
+		at, val int64
+		// opens, and the records left.
+		ok   bool
+		left int
+	}{
+		{"head at the end", 24, size - 2, false, 0},
+		{"head misaligned", 24, 3, false, 0},
+		{"tail misaligned", 32, 101, true, 0},
+		{"tail far ahead", 32, 10 * size, true, 0},
+		{"read misaligned", 40, 13, true, 20},
+		{"read past tail", 40, 8 * size, true, 20},
+	} {
+		path := filepath.Join(t.TempDir(), "ring")
+		m, err := OpenMmapRing(path, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint64(0); i < 20; i++ {
+			m.Append(mrRecord(r, i))
+		}
+		m.Close()
+
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteAt(binary.NativeEndian.AppendUint64(nil, uint64(tc.val)), tc.at)
+		f.Close()
+
+		m, err = OpenMmapRing(path, 0)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: open got %v", tc.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		cnt := 0
+		for rd := m.Reader(); ; cnt++ {
+			p, err := rd.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			mrCheck(t, p)
+		}
+		if cnt != tc.left {
+			t.Errorf("%s: %d records left, want %d", tc.name, cnt, tc.left)
+		}
+		m.Close()
+	}
+}
+
+// TestMmapRingTail tails a ring through its own mapping, as another
+// process would, while it is appended to.
+func TestMmapRingTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring")
+	m, err := OpenMmapRing(path, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	tm, err := TailMmapRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+	if tm.Append([]byte("x")) == nil {
+		t.Errorf("appended to a tailed ring")
+	}
+
+	const cnt = 20000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r := rand.New(rand.NewSource(3))
+		for i := uint64(1); i <= cnt; i++ {
+			m.Append(mrRecord(r, i))
+		}
+	}()
+
+	rd := tm.Reader()
+	seen, last := 0, uint64(0)
+	for last < cnt {
+		p, err := rd.Next()
+		if err == io.EOF {
+			runtime.Gosched()
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if i := mrCheck(t, p); i <= last {
+			t.Fatalf("record %d after %d", i, last)
+		} else {
+			last = i
+		}
+		seen++
+	}
+	<-done
+	t.Logf("tailed %d of %d records, lost %d bytes", seen, cnt, rd.Lost())
+}
+
+// mrProcSize is the size of record i appended by the child process of
+// TestMmapRingTailProcess, sizes vary so that appends wrap with padding
+// markers.
+func mrProcSize(i uint64) int {
+	return 8 + int(i*37%200)
+}
+
+// TestMmapRingTailProcess tails a ring appended to by another process,
+// this test binary run again, across padding markers.
+func TestMmapRingTailProcess(t *testing.T) {
+	const (
+		size = 2000
+		cnt  = 20000
+	)
+	if path := os.Getenv("GCL_MMAPRING_APPEND"); path != "" {
+		m, err := OpenMmapRing(path, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Close()
+		for i := uint64(1); i <= cnt; i++ {
+			p := binary.BigEndian.AppendUint64(nil, i)
+			// a failed test exits non zero, which fails the parent.
+			if err := m.Append(append(p, bytes.Repeat([]byte{byte(i)}, mrProcSize(i)-8)...)); err != nil {
+				t.Fatalf("append %d: %v", i, err)
+			}
+			if i%50 == 0 {
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+		return
+	}
+
+	path := filepath.Join(t.TempDir(), "ring")
+	m, err := OpenMmapRing(path, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+	tm, err := TailMmapRing(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tm.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMmapRingTailProcess$")
+	cmd.Env = append(os.Environ(), "GCL_MMAPRING_APPEND="+path)
+	out := make(chan error, 1)
+	go func() {
+		b, err := cmd.CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("%v: %s", err, b)
+		}
+		out <- err
+	}()
+
+	// a record read right after the one before it, that starts a lap
+	// while the one before did not end one, came after a padding marker.
+	rd := tm.Reader()
+	last, pads := uint64(0), 0
+	prevEnd, prevLost := uint64(0), uint64(0)
+	for last < cnt {
+		p, err := rd.Next()
+		if err == io.EOF {
+			select {
+			case err := <-out:
+				t.Fatalf("appender ended at record %d: %v", last, err)
+			default:
+			}
+			runtime.Gosched()
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		i := mrCheck(t, p)
+		if i <= last || len(p) != mrProcSize(i) {
+			t.Fatalf("record %d of %d bytes after %d", i, len(p), last)
+		}
+		start := rd.off - 8 - uint64(len(p)+7)&^7
+		if last != 0 && i == last+1 && rd.Lost() == prevLost && start%size == 0 && prevEnd%size != 0 {
+			pads++
+		}
+		last, prevEnd, prevLost = i, rd.off, rd.Lost()
+	}
+	if err := <-out; err != nil {
+		t.Fatal(err)
+	}
+	if pads == 0 {
+		t.Errorf("no record read across a padding marker")
+	}
+	t.Logf("read across %d padding markers, lost %d bytes", pads, rd.Lost())
+}
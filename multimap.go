@@ -0,0 +1,194 @@
+package gcl
+
+import (
+	"iter"
+	"sync/atomic"
+)
+
+//
+// Multimap allows duplicate keys on top of a Skiplist or a LazyList.
+// Each entry is stored as its own key (k, v, seq) in the underlying list,
+// so entries are added and removed with the list's own synchronization
+// and no slice of values is ever shared.   seq is unique and orders
+// entries of a key by insertion, or, with a value comparator, orders
+// equal values of a key.
+//
+// Lookups and removals by key start with a probe key that sorts before
+// all entries of k, or of (k, v).
+//
+
+const (
+	mmEntry = iota
+	// probe before all entries of (k, v).
+	mmProbeKV
+	// probe before all entries of k.
+	mmProbeK
+)
+
+type mmKey[K any, V any] struct {
+	k     K
+	v     V
+	seq   uint64
+	probe uint8
+}
+
+func mmCmp[K any, V any](kcmp func(a, b K) int, vcmp func(a, b V) int) func(a, b mmKey[K, V]) int {
+	return func(a, b mmKey[K, V]) int {
+		if c := kcmp(a.k, b.k); c != 0 {
+			return c
+		}
+		if a.probe == mmProbeK || b.probe == mmProbeK {
+			return probeCmp(a.probe == mmProbeK, b.probe == mmProbeK)
+		}
+		if vcmp != nil {
+			if c := vcmp(a.v, b.v); c != 0 {
+				return c
+			}
+		}
+		if a.probe != mmEntry || b.probe != mmEntry {
+			return probeCmp(a.probe != mmEntry, b.probe != mmEntry)
+		}
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	}
+}
+
+// a probe sorts first.
+func probeCmp(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	}
+	return 1
+}
+
+// mmList is the part of Skiplist and LazyList a Multimap uses.
+type mmList[K any, V any] interface {
+	Add(k K, v V) bool
+	Remove(k K) bool
+	All() iter.Seq2[K, V]
+	from(k K) iter.Seq2[K, V]
+}
+
+type Multimap[K any, V any] struct {
+	list mmList[mmKey[K, V], struct{}]
+	kcmp func(a, b K) int
+	veq  func(a, b V) bool
+	seq  atomic.Uint64
+}
+
+func eqOf[V comparable](a, b V) bool {
+	return a == b
+}
+
+func veqOf[V any](vcmp func(a, b V) int) func(a, b V) bool {
+	return func(a, b V) bool {
+		return vcmp(a, b) == 0
+	}
+}
+
+// NewSkipMultimap creates a multimap over a Skiplist ordered by cmp,
+// values of a key are kept in insertion order.
+func NewSkipMultimap[K any, V comparable](cmp func(a, b K) int, opts ...SkiplistOption) *Multimap[K, V] {
+	return &Multimap[K, V]{
+		list: NewSkipListFunc[mmKey[K, V], struct{}](mmCmp[K, V](cmp, nil), opts...),
+		kcmp: cmp,
+		veq:  eqOf[V],
+	}
+}
+
+// NewSkipMultimapFunc creates a multimap over a Skiplist ordered by cmp,
+// values of a key are kept in the order of vcmp.
+func NewSkipMultimapFunc[K any, V any](cmp func(a, b K) int, vcmp func(a, b V) int, opts ...SkiplistOption) *Multimap[K, V] {
+	return &Multimap[K, V]{
+		list: NewSkipListFunc[mmKey[K, V], struct{}](mmCmp(cmp, vcmp), opts...),
+		kcmp: cmp,
+		veq:  veqOf(vcmp),
+	}
+}
+
+// NewLazyMultimap creates a multimap over a LazyList ordered by cmp,
+// values of a key are kept in insertion order.
+func NewLazyMultimap[K any, V comparable](cmp func(a, b K) int, opts ...LazyListOption) *Multimap[K, V] {
+	return &Multimap[K, V]{
+		list: NewLazyListFunc[mmKey[K, V], struct{}](mmCmp[K, V](cmp, nil), opts...),
+		kcmp: cmp,
+		veq:  eqOf[V],
+	}
+}
+
+// NewLazyMultimapFunc creates a multimap over a LazyList ordered by cmp,
+// values of a key are kept in the order of vcmp.
+func NewLazyMultimapFunc[K any, V any](cmp func(a, b K) int, vcmp func(a, b V) int, opts ...LazyListOption) *Multimap[K, V] {
+	return &Multimap[K, V]{
+		list: NewLazyListFunc[mmKey[K, V], struct{}](mmCmp(cmp, vcmp), opts...),
+		kcmp: cmp,
+		veq:  veqOf(vcmp),
+	}
+}
+
+// AddDup adds the entry (k, v), even if k or (k, v) is already there.
+func (m *Multimap[K, V]) AddDup(k K, v V) {
+	m.list.Add(mmKey[K, V]{k: k, v: v, seq: m.seq.Add(1)}, struct{}{})
+}
+
+// entries iterates the entries of k, from the probe.
+func (m *Multimap[K, V]) entries(probe mmKey[K, V]) iter.Seq[mmKey[K, V]] {
+	return func(yield func(mmKey[K, V]) bool) {
+		for mk := range m.list.from(probe) {
+			if m.kcmp(mk.k, probe.k) != 0 || !yield(mk) {
+				return
+			}
+		}
+	}
+}
+
+// LookupAll returns the values of k, in order.
+func (m *Multimap[K, V]) LookupAll(k K) []V {
+	var vs []V
+	for mk := range m.entries(mmKey[K, V]{k: k, probe: mmProbeK}) {
+		vs = append(vs, mk.v)
+	}
+	return vs
+}
+
+// RemoveOne removes one entry (k, v), the first in order.   It returns
+// false if there is none.
+func (m *Multimap[K, V]) RemoveOne(k K, v V) bool {
+	for mk := range m.entries(mmKey[K, V]{k: k, v: v, probe: mmProbeKV}) {
+		// lost to a concurrent remove, try the next one.
+		if m.veq(mk.v, v) && m.list.Remove(mk) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveAll removes all entries of k, it returns how many it removed.
+func (m *Multimap[K, V]) RemoveAll(k K) int {
+	cnt := 0
+	for mk := range m.entries(mmKey[K, V]{k: k, probe: mmProbeK}) {
+		if m.list.Remove(mk) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// All iterates the entries in order.
+func (m *Multimap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for mk := range m.list.All() {
+			if !yield(mk.k, mk.v) {
+				return
+			}
+		}
+	}
+}
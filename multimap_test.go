@@ -0,0 +1,88 @@
+package gcl
+
+import (
+	"cmp"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMultimap(t *testing.T) {
+	maps := map[string]*Multimap[int, string]{
+		"skip":     NewSkipMultimap[int, string](cmp.Compare[int]),
+		"lazy":     NewLazyMultimap[int, string](cmp.Compare[int]),
+		"skipfunc": NewSkipMultimapFunc(cmp.Compare[int], cmp.Compare[string]),
+		"lazyfunc": NewLazyMultimapFunc(cmp.Compare[int], cmp.Compare[string]),
+	}
+	for name, m := range maps {
+		sorted := name == "skipfunc" || name == "lazyfunc"
+		m.AddDup(1, "x")
+		m.AddDup(2, "c")
+		m.AddDup(2, "a")
+		m.AddDup(2, "c")
+		m.AddDup(2, "b")
+		m.AddDup(3, "y")
+
+		want := "[c a c b]"
+		if sorted {
+			want = "[a b c c]"
+		}
+		if got := fmt.Sprint(m.LookupAll(2)); got != want {
+			t.Errorf("%s: lookup all got %s, want %s", name, got, want)
+		}
+		if !m.RemoveOne(2, "c") || m.RemoveOne(2, "z") || m.RemoveOne(4, "c") {
+			t.Errorf("%s: remove one", name)
+		}
+		want = "[a c b]"
+		if sorted {
+			want = "[a b c]"
+		}
+		if got := fmt.Sprint(m.LookupAll(2)); got != want {
+			t.Errorf("%s: after remove one got %s, want %s", name, got, want)
+		}
+		if n := m.RemoveAll(2); n != 3 || m.LookupAll(2) != nil {
+			t.Errorf("%s: remove all removed %d", name, n)
+		}
+		var all []string
+		for k, v := range m.All() {
+			all = append(all, fmt.Sprintf("%d%s", k, v))
+		}
+		if fmt.Sprint(all) != "[1x 3y]" {
+			t.Errorf("%s: left %v", name, all)
+		}
+	}
+}
+
+func TestMultimapConcurrent(t *testing.T) {
+	const (
+		thCnt   = 8
+		loopCnt = 500
+	)
+	for _, m := range []*Multimap[int, int]{
+		NewSkipMultimap[int, int](cmp.Compare[int]),
+		NewLazyMultimap[int, int](cmp.Compare[int]),
+	} {
+		// every thread adds and removes its own value under shared keys.
+		var wg sync.WaitGroup
+		for i := 0; i < thCnt; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < loopCnt; j++ {
+					m.AddDup(j%10, i)
+					m.AddDup(j%10, i)
+					if !m.RemoveOne(j%10, i) {
+						t.Errorf("lost value %d of key %d", i, j%10)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		for k := 0; k < 10; k++ {
+			if n := len(m.LookupAll(k)); n != thCnt*loopCnt/10 {
+				t.Errorf("key %d has %d values, want %d", k, n, thCnt*loopCnt/10)
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package gcl
+
+import (
+	"iter"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// Multi version skiplist.   Each key of the underlying Skiplist holds a
+// chain of versions, newest first, stamped by a commit timestamp.  A
+// Snapshot reads the newest version no later than its timestamp, so it
+// sees a consistent point-in-time view while writers continue.
+//
+// Readers never lock.  Writers find or create the chain of the key in
+// the underlying skiplist concurrently, then serialize on a short commit
+// section that pushes the version and advances the clock.
+//
+// Versions older than the oldest live snapshot are pruned when the key
+// is written again, or by Vacuum.   A key whose only version is a pruned
+// tombstone is removed from the underlying skiplist.
+//
+
+type mvVersion[V any] struct {
+	ts      uint64
+	val     V
+	deleted bool
+	prev    atomic.Pointer[mvVersion[V]]
+}
+
+type mvChain[V any] struct {
+	head atomic.Pointer[mvVersion[V]]
+	// sealed chains are being removed from the skiplist, writers must
+	// retry with a new chain.
+	sealed atomic.Bool
+}
+
+// visible returns the newest version at or before ts.
+func (c *mvChain[V]) visible(ts uint64) *mvVersion[V] {
+	v := c.head.Load()
+	for v != nil && v.ts > ts {
+		v = v.prev.Load()
+	}
+	return v
+}
+
+type MVSkiplist[K any, V any] struct {
+	sl *Skiplist[K, *mvChain[V]]
+
+	// commitMu serializes commits, clock is the timestamp of the latest
+	// commit.   Every version with ts <= clock is visible in its chain.
+	commitMu sync.Mutex
+	clock    atomic.Uint64
+
+	// timestamps of live snapshots, with ref counts.
+	snapMu sync.Mutex
+	snaps  map[uint64]int
+}
+
+func NewMVSkiplist[K any, V any](less, eq func(a, b K) bool, opts ...SkiplistOption) *MVSkiplist[K, V] {
+	return NewMVSkiplistFunc[K, V](cmpOf(less, eq), opts...)
+}
+
+// NewMVSkiplistFunc creates a multi version skiplist ordered by the
+// three-way comparator cmp.
+func NewMVSkiplistFunc[K any, V any](cmp func(a, b K) int, opts ...SkiplistOption) *MVSkiplist[K, V] {
+	return &MVSkiplist[K, V]{
+		sl:    NewSkipListFunc[K, *mvChain[V]](cmp, opts...),
+		snaps: make(map[uint64]int),
+	}
+}
+
+// oldest returns the oldest timestamp any reader may still read at.
+func (m *MVSkiplist[K, V]) oldest() uint64 {
+	m.snapMu.Lock()
+	defer m.snapMu.Unlock()
+	ts := m.clock.Load()
+	for s := range m.snaps {
+		ts = min(ts, s)
+	}
+	return ts
+}
+
+// chain returns the live chain of k, creating an empty one if needed.
+func (m *MVSkiplist[K, V]) chain(k K) *mvChain[V] {
+	for {
+		if c, ok := m.sl.Lookup(k); ok && !c.sealed.Load() {
+			return c
+		}
+		c := &mvChain[V]{}
+		if m.sl.Add(k, c) {
+			return c
+		}
+	}
+}
+
+// commit runs fn on the chain of k in the commit section.   fn returns
+// the new version to push, or nil to leave the chain alone.
+func (m *MVSkiplist[K, V]) commit(k K, fn func(head *mvVersion[V]) *mvVersion[V]) bool {
+	for {
+		c := m.chain(k)
+		m.commitMu.Lock()
+		if c.sealed.Load() {
+			// removed while we were not looking, retry with a new chain.
+			m.commitMu.Unlock()
+			continue
+		}
+
+		head := c.head.Load()
+		nv := fn(head)
+		if nv != nil {
+			nv.ts = m.clock.Load() + 1
+			nv.prev.Store(head)
+			c.head.Store(nv)
+			m.clock.Store(nv.ts)
+		}
+		m.prune(k, c, m.oldest())
+		m.commitMu.Unlock()
+		return nv != nil
+	}
+}
+
+// prune drops versions that no reader can see, must hold commitMu.
+func (m *MVSkiplist[K, V]) prune(k K, c *mvChain[V], oldest uint64) {
+	v := c.visible(oldest)
+	if v != nil {
+		v.prev.Store(nil)
+	}
+	// empty chains and old tombstones can go.
+	if v == c.head.Load() && (v == nil || v.deleted) {
+		c.sealed.Store(true)
+		m.sl.Remove(k)
+	}
+}
+
+// Add key, val to the list.  Return true if added, false if already exists
+func (m *MVSkiplist[K, V]) Add(k K, v V) bool {
+	return m.commit(k, func(head *mvVersion[V]) *mvVersion[V] {
+		if head != nil && !head.deleted {
+			return nil
+		}
+		return &mvVersion[V]{val: v}
+	})
+}
+
+// Put sets the value of k, adding it if it does not exist.
+func (m *MVSkiplist[K, V]) Put(k K, v V) {
+	m.commit(k, func(head *mvVersion[V]) *mvVersion[V] {
+		return &mvVersion[V]{val: v}
+	})
+}
+
+// Remove key from the list.  Return true if removed, false if not found
+func (m *MVSkiplist[K, V]) Remove(k K) bool {
+	return m.commit(k, func(head *mvVersion[V]) *mvVersion[V] {
+		if head == nil || head.deleted {
+			return nil
+		}
+		return &mvVersion[V]{deleted: true}
+	})
+}
+
+// Lookup the latest value of k.   Like All, it reads the newest version,
+// a timestamp not held by a snapshot may be pruned while it is read.
+func (m *MVSkiplist[K, V]) Lookup(k K) (V, bool) {
+	var zero V
+	c, ok := m.sl.Lookup(k)
+	if !ok {
+		return zero, false
+	}
+	v := c.head.Load()
+	if v == nil || v.deleted {
+		return zero, false
+	}
+	return v.val, true
+}
+
+func (m *MVSkiplist[K, V]) lookupAt(k K, ts uint64) (V, bool) {
+	var zero V
+	c, ok := m.sl.Lookup(k)
+	if !ok {
+		return zero, false
+	}
+	v := c.visible(ts)
+	if v == nil || v.deleted {
+		return zero, false
+	}
+	return v.val, true
+}
+
+func (m *MVSkiplist[K, V]) allAt(ts uint64) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		defer m.sl.Pin().Unpin()
+		for n := m.sl.First(); n != nil; n = m.sl.Next(n) {
+			v := n.GetV().visible(ts)
+			if v == nil || v.deleted {
+				continue
+			}
+			if !yield(n.GetK(), v.val) {
+				return
+			}
+		}
+	}
+}
+
+// All iterates the latest key value pairs in order.   Like Skiplist,
+// concurrent writes may or may not be seen, use a Snapshot for a
+// consistent view.
+func (m *MVSkiplist[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		defer m.sl.Pin().Unpin()
+		for n := m.sl.First(); n != nil; n = m.sl.Next(n) {
+			v := n.GetV().head.Load()
+			if v == nil || v.deleted {
+				continue
+			}
+			if !yield(n.GetK(), v.val) {
+				return
+			}
+		}
+	}
+}
+
+// Vacuum prunes versions that no snapshot can see from every key.
+func (m *MVSkiplist[K, V]) Vacuum() {
+	m.commitMu.Lock()
+	defer m.commitMu.Unlock()
+	oldest := m.oldest()
+	defer m.sl.Pin().Unpin()
+	for n := m.sl.First(); n != nil; n = m.sl.Next(n) {
+		m.prune(n.GetK(), n.GetV(), oldest)
+	}
+}
+
+// MVSnapshot is a read only, point-in-time view of a MVSkiplist.
+// It must be closed so that old versions can be reclaimed.
+type MVSnapshot[K any, V any] struct {
+	m      *MVSkiplist[K, V]
+	ts     uint64
+	closed atomic.Bool
+}
+
+// Snapshot returns a view of the list as of the latest commit.
+func (m *MVSkiplist[K, V]) Snapshot() *MVSnapshot[K, V] {
+	m.snapMu.Lock()
+	defer m.snapMu.Unlock()
+	ts := m.clock.Load()
+	m.snaps[ts]++
+	return &MVSnapshot[K, V]{m: m, ts: ts}
+}
+
+// Lookup the value of k as of the snapshot.
+func (s *MVSnapshot[K, V]) Lookup(k K) (V, bool) {
+	return s.m.lookupAt(k, s.ts)
+}
+
+// All iterates the key value pairs of the snapshot in order.
+func (s *MVSnapshot[K, V]) All() iter.Seq2[K, V] {
+	return s.m.allAt(s.ts)
+}
+
+// Close releases the snapshot, it must not be used after Close.
+func (s *MVSnapshot[K, V]) Close() {
+	if s.closed.Swap(true) {
+		return
+	}
+	s.m.snapMu.Lock()
+	defer s.m.snapMu.Unlock()
+	if s.m.snaps[s.ts]--; s.m.snaps[s.ts] == 0 {
+		delete(s.m.snaps, s.ts)
+	}
+}
@@ -0,0 +1,153 @@
+package gcl
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMVSkiplist(t *testing.T) {
+	list := NewMVSkiplist[int, int](
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+	)
+
+	list.Add(1, 10)
+	list.Add(2, 20)
+	snap := list.Snapshot()
+	list.Put(1, 11)
+	list.Remove(2)
+	list.Add(3, 30)
+
+	if v, ok := snap.Lookup(1); !ok || v != 10 {
+		t.Errorf("snapshot lookup 1 got %d, %v", v, ok)
+	}
+	if _, ok := snap.Lookup(3); ok {
+		t.Errorf("snapshot should not see 3")
+	}
+	var keys []int
+	for k := range snap.All() {
+		keys = append(keys, k)
+	}
+	check(t, []int{1, 2}, keys)
+
+	keys = keys[:0]
+	for k, v := range list.All() {
+		keys = append(keys, k, v)
+	}
+	check(t, []int{1, 11, 3, 30}, keys)
+
+	// old versions are kept while the snapshot is open.
+	list.Vacuum()
+	if _, ok := list.sl.Lookup(2); !ok {
+		t.Errorf("tombstone of 2 should be kept for the snapshot")
+	}
+	snap.Close()
+	list.Vacuum()
+	if _, ok := list.sl.Lookup(2); ok {
+		t.Errorf("tombstone of 2 should be reclaimed")
+	}
+	if c, _ := list.sl.Lookup(1); c.head.Load().prev.Load() != nil {
+		t.Errorf("old versions of 1 should be reclaimed")
+	}
+}
+
+func TestMVSkiplistConcurrent(t *testing.T) {
+	const (
+		nWrite  = 5000
+		window  = 100
+		readers = 4
+	)
+	list := NewMVSkiplist[int, int](
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+	)
+
+	// the writer keeps a window of consecutive keys, so any consistent
+	// view is a run of at most window+1 consecutive keys.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < nWrite; i++ {
+			list.Add(i, i)
+			if i >= window {
+				list.Remove(i - window)
+			}
+		}
+	}()
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snap := snapOK(t, list, window)
+				if !snap {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// snapOK checks a snapshot is a run of at most window+1 consecutive keys.
+func snapOK(t *testing.T, list *MVSkiplist[int, int], window int) bool {
+	snap := list.Snapshot()
+	defer snap.Close()
+	prev, cnt := -1, 0
+	for k, v := range snap.All() {
+		if k != v || (prev >= 0 && k != prev+1) {
+			t.Errorf("inconsistent snapshot, %d after %d", k, prev)
+			return false
+		}
+		if _, ok := snap.Lookup(k); !ok {
+			t.Errorf("snapshot lookup missed %d", k)
+			return false
+		}
+		prev = k
+		cnt++
+	}
+	if cnt > window+1 {
+		t.Errorf("snapshot has %d keys", cnt)
+		return false
+	}
+	return true
+}
+
+// TestMVSkiplistLookupPut looks up a key while it is put, every write
+// prunes the versions before it.
+func TestMVSkiplistLookupPut(t *testing.T) {
+	list := NewMVSkiplist[int, int](
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+	)
+	list.Put(1, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i < 200000; i++ {
+			list.Put(1, i)
+		}
+	}()
+	for missed := 0; ; {
+		select {
+		case <-done:
+			if missed > 0 {
+				t.Errorf("lookup missed a present key %d times", missed)
+			}
+			return
+		default:
+		}
+		if _, ok := list.Lookup(1); !ok {
+			missed++
+		}
+	}
+}
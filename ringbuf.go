@@ -2,9 +2,13 @@ package gcl
 
 import "fmt"
 
+// RingBuffer is a double ended queue in a circular buffer, with one slot
+// always empty to tell full from empty.   When full, a push overwrites
+// the element at the other end, or, if the buffer grows, doubles it.
 type RingBuffer[T any] struct {
 	buf        []T
 	begin, end int
+	grow       bool
 }
 
 func NewRingBuffer[T any](size int) *RingBuffer[T] {
@@ -14,6 +18,46 @@ func NewRingBuffer[T any](size int) *RingBuffer[T] {
 	return &RingBuffer[T]{buf: make([]T, size+1)}
 }
 
+// NewGrowingRingBuffer creates a ring buffer that grows when full instead
+// of overwriting.
+func NewGrowingRingBuffer[T any](size int) *RingBuffer[T] {
+	rb := NewRingBuffer[T](size)
+	if rb != nil {
+		rb.grow = true
+	}
+	return rb
+}
+
+// back returns the position before n.
+func (rb *RingBuffer[T]) back(n int) int {
+	if n == 0 {
+		return len(rb.buf) - 1
+	}
+	return n - 1
+}
+
+// Cap returns the number of elements the buffer holds before it is full.
+func (rb *RingBuffer[T]) Cap() int {
+	return len(rb.buf) - 1
+}
+
+func (rb *RingBuffer[T]) full() bool {
+	return rb.Len() == rb.Cap()
+}
+
+// resize moves the elements, in order, to a buffer of size elements.
+func (rb *RingBuffer[T]) resize(size int) {
+	buf := make([]T, size+1)
+	n := rb.Len()
+	if rb.begin <= rb.end {
+		copy(buf, rb.buf[rb.begin:rb.end])
+	} else {
+		m := copy(buf, rb.buf[rb.begin:])
+		copy(buf[m:], rb.buf[:rb.end])
+	}
+	rb.buf, rb.begin, rb.end = buf, 0, n
+}
+
 func (rb *RingBuffer[T]) advance(n int) int {
 	ret := n + 1
 	if ret == len(rb.buf) {
@@ -30,8 +74,12 @@ func (rb *RingBuffer[T]) Len() int {
 	return n
 }
 
-// Put puts v into the ring buffer, will overwrite the oldest element if the buffer is full.
+// Push puts v at the back of the ring buffer, will overwrite the oldest
+// element if the buffer is full and does not grow.
 func (rb *RingBuffer[T]) Push(v T) {
+	if rb.grow && rb.full() {
+		rb.resize(2 * rb.Cap())
+	}
 	rb.buf[rb.end] = v
 	rb.end = rb.advance(rb.end)
 	if rb.end == rb.begin {
@@ -39,18 +87,26 @@ func (rb *RingBuffer[T]) Push(v T) {
 	}
 }
 
+// PushFront puts v at the front of the ring buffer, will overwrite the
+// newest element if the buffer is full and does not grow.
+func (rb *RingBuffer[T]) PushFront(v T) {
+	if rb.grow && rb.full() {
+		rb.resize(2 * rb.Cap())
+	}
+	rb.begin = rb.back(rb.begin)
+	rb.buf[rb.begin] = v
+	if rb.begin == rb.end {
+		rb.end = rb.back(rb.end)
+	}
+}
+
 func (rb *RingBuffer[T]) ReplaceLast(v T) {
 	if rb.Len() == 0 {
 		rb.Push(v)
 		return
 	}
 
-	// replacement position
-	pos := rb.end - 1
-	if rb.end == 0 {
-		pos = len(rb.buf) - 1
-	}
-	rb.buf[pos] = v
+	rb.buf[rb.back(rb.end)] = v
 }
 
 func (rb *RingBuffer[T]) PopFront() (T, error) {
@@ -59,7 +115,9 @@ func (rb *RingBuffer[T]) PopFront() (T, error) {
 		return zero, fmt.Errorf("RingBuffer is empty")
 	}
 
+	var zero T
 	var v = rb.buf[rb.begin]
+	rb.buf[rb.begin] = zero
 	rb.begin = rb.advance(rb.begin)
 	return v, nil
 }
@@ -69,15 +127,38 @@ func (rb *RingBuffer[T]) PopBack() (T, error) {
 		var zero T
 		return zero, fmt.Errorf("RingBuffer is empty")
 	}
+	// end is one past the last element.
+	var zero T
+	rb.end = rb.back(rb.end)
 	var v = rb.buf[rb.end]
-	if rb.end == 0 {
-		rb.end = len(rb.buf) - 1
-	} else {
-		rb.end--
-	}
+	rb.buf[rb.end] = zero
 	return v, nil
 }
 
+// PeekFront returns the first element without removing it.
+func (rb *RingBuffer[T]) PeekFront() (T, error) {
+	if rb.Len() == 0 {
+		var zero T
+		return zero, fmt.Errorf("RingBuffer is empty")
+	}
+	return rb.buf[rb.begin], nil
+}
+
+// PeekBack returns the last element without removing it.
+func (rb *RingBuffer[T]) PeekBack() (T, error) {
+	if rb.Len() == 0 {
+		var zero T
+		return zero, fmt.Errorf("RingBuffer is empty")
+	}
+	return rb.buf[rb.back(rb.end)], nil
+}
+
+// Clear removes all elements.
+func (rb *RingBuffer[T]) Clear() {
+	clear(rb.buf)
+	rb.begin, rb.end = 0, 0
+}
+
 func (rb *RingBuffer[T]) Get(n int) (T, error) {
 	if n >= rb.Len() {
 		var zero T
@@ -1,6 +1,11 @@
 package gcl
 
-import "testing"
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func getAll(rb *RingBuffer[int]) []int {
 	var ret []int
@@ -43,3 +48,158 @@ func TestChat(t *testing.T) {
 	rb.ReplaceLast(100)
 	check(t, []int{3, 100}, getAll(rb))
 }
+
+// ringModel is a slice with the semantics of a RingBuffer.
+type ringModel struct {
+	s    []int
+	cap  int
+	grow bool
+}
+
+func (m *ringModel) push(v int, front bool) {
+	if !m.grow && len(m.s) == m.cap {
+		// overwrite at the other end.
+		if front {
+			m.s = m.s[:len(m.s)-1]
+		} else {
+			m.s = m.s[1:]
+		}
+	}
+	if front {
+		m.s = append([]int{v}, m.s...)
+	} else {
+		m.s = append(m.s, v)
+	}
+}
+
+func TestRingBufferDeque(t *testing.T) {
+	type op struct {
+		name string
+		v    int
+	}
+	ops := func(names ...string) []op {
+		var ret []op
+		for i, n := range names {
+			ret = append(ret, op{n, i + 1})
+		}
+		return ret
+	}
+	tests := []struct {
+		name string
+		grow bool
+		ops  []op
+	}{
+		{"popback", false, ops("push", "push", "popback", "popback", "popback")},
+		{"pushfront", false, ops("pushfront", "pushfront", "push", "popfront", "popback", "popback")},
+		{"overwrite", false, ops("push", "push", "push", "push", "pushfront", "pushfront", "popback")},
+		{"wrap", false, ops("push", "push", "popfront", "push", "push", "popfront", "push", "pushfront", "popback", "popback")},
+		{"peek", false, ops("pushfront", "peekfront", "peekback", "push", "peekback", "replacelast", "peekback")},
+		{"clear", false, ops("push", "push", "clear", "popback", "pushfront", "peekfront")},
+		{"grow", true, ops("push", "push", "push", "push", "pushfront", "pushfront", "push", "popfront", "popback")},
+		{"growwrap", true, ops("push", "popfront", "push", "push", "pushfront", "pushfront", "pushfront", "popback")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rb := NewRingBuffer[int](3)
+			if tc.grow {
+				rb = NewGrowingRingBuffer[int](3)
+			}
+			m := &ringModel{cap: 3, grow: tc.grow}
+			for i, o := range tc.ops {
+				var got, want int
+				var err error
+				switch o.name {
+				case "push":
+					rb.Push(o.v)
+					m.push(o.v, false)
+				case "pushfront":
+					rb.PushFront(o.v)
+					m.push(o.v, true)
+				case "replacelast":
+					rb.ReplaceLast(o.v)
+					if len(m.s) == 0 {
+						m.push(o.v, false)
+					} else {
+						m.s[len(m.s)-1] = o.v
+					}
+				case "popfront", "peekfront":
+					if o.name == "popfront" {
+						got, err = rb.PopFront()
+					} else {
+						got, err = rb.PeekFront()
+					}
+					if len(m.s) > 0 {
+						want = m.s[0]
+						if o.name == "popfront" {
+							m.s = m.s[1:]
+						}
+					}
+				case "popback", "peekback":
+					if o.name == "popback" {
+						got, err = rb.PopBack()
+					} else {
+						got, err = rb.PeekBack()
+					}
+					if len(m.s) > 0 {
+						want = m.s[len(m.s)-1]
+						if o.name == "popback" {
+							m.s = m.s[:len(m.s)-1]
+						}
+					}
+				case "clear":
+					rb.Clear()
+					m.s = nil
+				}
+				// values are positive, 0 is an empty buffer.
+				read := strings.HasPrefix(o.name, "pop") || strings.HasPrefix(o.name, "peek")
+				if read && ((err != nil) != (want == 0) || got != want) {
+					t.Fatalf("op %d %s got %d, %v, want %d", i, o.name, got, err, want)
+				}
+				if all := getAll(rb); !slices.Equal(all, m.s) {
+					t.Fatalf("op %d %s buffer %v, want %v", i, o.name, all, m.s)
+				}
+			}
+			if !tc.grow && rb.Cap() != 3 {
+				t.Errorf("cap %d", rb.Cap())
+			}
+		})
+	}
+}
+
+func TestRingBufferRandom(t *testing.T) {
+	for _, grow := range []bool{false, true} {
+		rb := NewRingBuffer[int](5)
+		if grow {
+			rb = NewGrowingRingBuffer[int](5)
+		}
+		m := &ringModel{cap: 5, grow: grow}
+		r := rand.New(rand.NewSource(1))
+		for i := 1; i < 10000; i++ {
+			switch r.Intn(4) {
+			case 0:
+				rb.Push(i)
+				m.push(i, false)
+			case 1:
+				rb.PushFront(i)
+				m.push(i, true)
+			case 2:
+				if v, err := rb.PopFront(); err == nil {
+					if v != m.s[0] {
+						t.Fatalf("step %d popfront %d, want %d", i, v, m.s[0])
+					}
+					m.s = m.s[1:]
+				}
+			case 3:
+				if v, err := rb.PopBack(); err == nil {
+					if v != m.s[len(m.s)-1] {
+						t.Fatalf("step %d popback %d, want %d", i, v, m.s[len(m.s)-1])
+					}
+					m.s = m.s[:len(m.s)-1]
+				}
+			}
+			if rb.Len() != len(m.s) {
+				t.Fatalf("step %d len %d, want %d", i, rb.Len(), len(m.s))
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gcl
+
+import "iter"
+
+//
+// Set algebra over sorted sequences.   The inputs are key value sequences
+// in strictly ascending order by cmp, such as the All iterator of any gcl
+// ordered container.   The result streams in the same order, without
+// copying the inputs, so it can be ranged over directly or fed to
+// BuildSkiplistFromSorted to make a new container.
+//
+// When a key is in both inputs, the value from a is used.
+//
+
+// mergeSorted walks a and b in lock step.  For each key, in ascending
+// order, it calls emit with which inputs have the key.
+func mergeSorted[K any, V any](a, b iter.Seq2[K, V], cmp func(a, b K) int,
+	emit func(k K, v V, inA, inB bool) bool) {
+	nextB, stop := iter.Pull2(b)
+	defer stop()
+	kb, vb, okb := nextB()
+
+	for ka, va := range a {
+		c := 1
+		for okb {
+			if c = cmp(kb, ka); c >= 0 {
+				break
+			}
+			if !emit(kb, vb, false, true) {
+				return
+			}
+			kb, vb, okb = nextB()
+		}
+		inB := okb && c == 0
+		if inB {
+			kb, vb, okb = nextB()
+		}
+		if !emit(ka, va, true, inB) {
+			return
+		}
+	}
+
+	for okb {
+		if !emit(kb, vb, false, true) {
+			return
+		}
+		kb, vb, okb = nextB()
+	}
+}
+
+// setOp yields the keys for which keep(inA, inB) is true.
+func setOp[K any, V any](a, b iter.Seq2[K, V], cmp func(a, b K) int, keep func(inA, inB bool) bool) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		mergeSorted(a, b, cmp, func(k K, v V, inA, inB bool) bool {
+			return !keep(inA, inB) || yield(k, v)
+		})
+	}
+}
+
+// Union yields keys in a or b.
+func Union[K any, V any](a, b iter.Seq2[K, V], cmp func(a, b K) int) iter.Seq2[K, V] {
+	return setOp(a, b, cmp, func(inA, inB bool) bool { return true })
+}
+
+// Intersect yields keys in both a and b.
+func Intersect[K any, V any](a, b iter.Seq2[K, V], cmp func(a, b K) int) iter.Seq2[K, V] {
+	return setOp(a, b, cmp, func(inA, inB bool) bool { return inA && inB })
+}
+
+// Difference yields keys in a but not in b.
+func Difference[K any, V any](a, b iter.Seq2[K, V], cmp func(a, b K) int) iter.Seq2[K, V] {
+	return setOp(a, b, cmp, func(inA, inB bool) bool { return inA && !inB })
+}
+
+// SymmetricDifference yields keys in exactly one of a and b.
+func SymmetricDifference[K any, V any](a, b iter.Seq2[K, V], cmp func(a, b K) int) iter.Seq2[K, V] {
+	return setOp(a, b, cmp, func(inA, inB bool) bool { return inA != inB })
+}
@@ -0,0 +1,92 @@
+package gcl
+
+import (
+	"cmp"
+	"iter"
+	"math/rand"
+	"testing"
+)
+
+func TestSetOps(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	eq := func(a, b int) bool { return a == b }
+
+	for round := 0; round < 20; round++ {
+		sl := NewSkipList[int, int](less, eq)
+		ll := NewLazyList[int, int](less, eq)
+		inA := make(map[int]bool)
+		inB := make(map[int]bool)
+		for i := 0; i < 100; i++ {
+			k := rand.Intn(150)
+			sl.Add(k, k)
+			inA[k] = true
+			k = rand.Intn(150)
+			ll.Add(k, -k)
+			inB[k] = true
+		}
+
+		for _, tc := range []struct {
+			name string
+			op   func(a, b iter.Seq2[int, int], cmp func(a, b int) int) iter.Seq2[int, int]
+			keep func(a, b bool) bool
+		}{
+			{"union", Union[int, int], func(a, b bool) bool { return a || b }},
+			{"intersect", Intersect[int, int], func(a, b bool) bool { return a && b }},
+			{"difference", Difference[int, int], func(a, b bool) bool { return a && !b }},
+			{"symdiff", SymmetricDifference[int, int], func(a, b bool) bool { return a != b }},
+		} {
+			var want []int
+			for k := 0; k < 150; k++ {
+				if tc.keep(inA[k], inB[k]) {
+					want = append(want, k)
+				}
+			}
+			var got []int
+			for k, v := range tc.op(sl.All(), ll.All(), cmp.Compare[int]) {
+				// values come from a when the key is in both.
+				if (inA[k] && v != k) || (!inA[k] && v != -k) {
+					t.Fatalf("%s: key %d has value %d", tc.name, k, v)
+				}
+				got = append(got, k)
+			}
+			check(t, want, got)
+
+			// results can be built into a new container.
+			res := Must(BuildSkiplistFromSorted(less, eq, tc.op(sl.All(), ll.All(), cmp.Compare[int])))
+			check(t, want, skKeys(res))
+		}
+	}
+
+	// early break stops both inputs, neither is read past 15, the next
+	// key of b after the break at 12 or 14.
+	for _, op := range []func(a, b iter.Seq2[int, int], cmp func(a, b int) int) iter.Seq2[int, int]{
+		Union[int, int], Intersect[int, int], Difference[int, int], SymmetricDifference[int, int],
+	} {
+		a, aCnt, aDone := countSeq(100, 2)
+		b, bCnt, bDone := countSeq(100, 3)
+		for k := range op(a, b, cmp.Compare[int]) {
+			if k >= 12 {
+				break
+			}
+		}
+		if *aCnt > 8 || *bCnt > 6 || !*aDone || !*bDone {
+			t.Errorf("after break, inputs yielded %d, %d, stopped %v, %v", *aCnt, *bCnt, *aDone, *bDone)
+		}
+	}
+}
+
+// countSeq yields n multiples of step, it counts the keys yielded and
+// sets done when it returns.
+func countSeq(n, step int) (iter.Seq2[int, int], *int, *bool) {
+	var cnt int
+	var done bool
+	return func(yield func(int, int) bool) {
+		defer func() { done = true }()
+		for i := 0; i < n; i++ {
+			cnt++
+			if !yield(i*step, i*step) {
+				return
+			}
+		}
+	}, &cnt, &done
+}
@@ -1,9 +1,14 @@
 package gcl
 
 import (
-	"math/rand"
+	"cmp"
+	"fmt"
+	"iter"
+	"math/rand/v2"
 	"sync"
 	"sync/atomic"
+
+	"github.com/google/uuid"
 )
 
 //
@@ -11,12 +16,123 @@ import (
 // The Art of Multiprocessor Programming, Chapter 14
 //
 
-// maxLevel at 7, bottom level is level 0, so we have at most 8 levels.
+// maxLevel at 7, bottom level is level 0, so by default we have at most 8
+// levels.  A list can be configured up to maxNumLevel levels, which is
+// plenty for any list that fits in memory.
 const (
-	maxLevel = 7
-	numLevel = 8
+	maxLevel    = 7
+	numLevel    = 8
+	maxNumLevel = 32
+	defaultP    = 0.25
 )
 
+// SkiplistOption configures a Skiplist at construction time.
+type SkiplistOption func(*skiplistConfig)
+
+type skiplistConfig struct {
+	maxLv int
+	// maxLv was set by WithMaxLevel, WithExpectedSize does not raise it.
+	maxSet   bool
+	p        float64
+	expected int
+	src      rand.Source
+	reclaim  bool
+}
+
+// WithMaxLevel sets the maximum number of levels of the skiplist, in
+// [1, 32].   A list with branching factor 1/p stays logarithmic up to
+// roughly (1/p)^levels keys.
+func WithMaxLevel(levels int) SkiplistOption {
+	return func(c *skiplistConfig) {
+		MustCheck(levels >= 1 && levels <= maxNumLevel, "skiplist levels must be in [1, 32]")
+		c.maxLv = levels - 1
+		c.maxSet = true
+	}
+}
+
+// WithProbability sets the probability that a node at level N is also
+// at level N+1.  Must be in (0, 1), default is 1/4.
+func WithProbability(p float64) SkiplistOption {
+	return func(c *skiplistConfig) {
+		MustCheck(p > 0 && p < 1, "skiplist probability must be in (0, 1)")
+		c.p = p
+	}
+}
+
+// WithRandSource makes the list draw node levels from src instead of the
+// global generator.   With a seeded source, a list built by the same
+// sequence of single threaded operations always has the same shape.
+// The source is guarded by a mutex, so it is safe but not contention
+// free under concurrent Add.
+func WithRandSource(src rand.Source) SkiplistOption {
+	return func(c *skiplistConfig) {
+		c.src = src
+	}
+}
+
+// WithSeed is a shortcut of WithRandSource with a PCG source seeded by seed.
+func WithSeed(seed uint64) SkiplistOption {
+	return WithRandSource(rand.NewPCG(seed, seed))
+}
+
+// WithReclamation recycles removed nodes once no operation can still
+// reach them, see epoch.go.   Nodes held across calls, from First and
+// Next, must be guarded by Pin.
+func WithReclamation() SkiplistOption {
+	return func(c *skiplistConfig) {
+		c.reclaim = true
+	}
+}
+
+// WithExpectedSize raises the number of levels so that a list holding
+// about n keys stays logarithmic with the configured probability, up to
+// the levels set by WithMaxLevel.   The levels are fixed when the list
+// is created, a list that grows well past n keys slows down as its top
+// level gets long, so n should be a bound, not a guess.
+func WithExpectedSize(n int) SkiplistOption {
+	return func(c *skiplistConfig) {
+		c.expected = n
+	}
+}
+
+// levelGen draws the level of new nodes.
+type levelGen struct {
+	// top level of the list and probability of promoting a node.
+	maxLv int
+	p     float64
+	// optional private random source, nil uses the global generator of
+	// math/rand/v2, which is per thread and takes no lock.
+	rng   *rand.Rand
+	rngMu sync.Mutex
+}
+
+func (c *skiplistConfig) init(lg *levelGen) {
+	lg.maxLv = c.levels()
+	lg.p = c.p
+	if c.src != nil {
+		lg.rng = rand.New(c.src)
+	}
+}
+
+func newSkiplistConfig(opts []SkiplistOption) skiplistConfig {
+	cfg := skiplistConfig{maxLv: maxLevel, p: defaultP}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (c *skiplistConfig) levels() int {
+	if c.maxSet {
+		return c.maxLv
+	}
+	lv := 1
+	for sz := 1 / c.p; sz < float64(c.expected) && lv < maxNumLevel; sz /= c.p {
+		lv++
+	}
+	return max(lv-1, c.maxLv)
+}
+
 type SkNode[K any, V any] struct {
 	key         K
 	val         V
@@ -24,13 +140,19 @@ type SkNode[K any, V any] struct {
 	marked      atomic.Bool
 	fullyLinked atomic.Bool
 	lock        sync.Mutex
-	next        [numLevel]atomic.Pointer[SkNode[K, V]]
+	// value replaced by Put, overrides val.   val itself is never written
+	// once the node is linked, so it can be read without a lock.
+	upd  atomic.Pointer[V]
+	next []atomic.Pointer[SkNode[K, V]]
 }
 
 func (n *SkNode[K, V]) GetK() K {
 	return n.key
 }
 func (n *SkNode[K, V]) GetV() V {
+	if p := n.upd.Load(); p != nil {
+		return *p
+	}
 	return n.val
 }
 
@@ -42,56 +164,126 @@ func (n *SkNode[K, V]) nextIs(lv int, curr *SkNode[K, V]) bool {
 	return n.next[lv].Load() == curr
 }
 
-// new node is created at a random level in [0, maxLevel)
-// It is probalistic -- probablity 3/4(1/4)^N at lv N.
-func randomLv() int {
-	n := 1 << (numLevel * 2)
-	r := rand.Intn(n)
-	for i := 0; i < maxLevel; i++ {
-		if r >= 1<<((maxLevel-i)*2) {
-			return i
-		}
-	}
-	return maxLevel - 1
-}
-
 // create a new node at a level.
 func newNode[K any, V any](k K, v V, lv int) *SkNode[K, V] {
 	return &SkNode[K, V]{
 		key: k, val: v, topLv: lv,
+		next: make([]atomic.Pointer[SkNode[K, V]], lv+1),
 	}
 }
 
 type Skiplist[K any, V any] struct {
 	head, tail *SkNode[K, V]
-	less       func(a, b K) bool
-	eq         func(a, b K) bool
+	cmp        func(a, b K) int
+	levelGen
+	// optional node recycling, pools are per level.
+	rc    *reclaimer[SkNode[K, V]]
+	pools []sync.Pool
+}
+
+// NewSkipList creates a skiplist ordered by less, with eq for equality.
+func NewSkipList[K any, V any](less, eq func(a, b K) bool, opts ...SkiplistOption) *Skiplist[K, V] {
+	return NewSkipListFunc[K, V](cmpOf(less, eq), opts...)
 }
 
-func NewSkipList[K any, V any](less, eq func(a, b K) bool) *Skiplist[K, V] {
+// NewSkipListFunc creates a skiplist ordered by the three-way comparator
+// cmp, which returns a negative number, zero or a positive number when
+// a < b, a == b or a > b.
+func NewSkipListFunc[K any, V any](cmp func(a, b K) int, opts ...SkiplistOption) *Skiplist[K, V] {
+	cfg := newSkiplistConfig(opts)
 	var lsl Skiplist[K, V]
-	lsl.head = &SkNode[K, V]{topLv: maxLevel}
-	lsl.tail = &SkNode[K, V]{topLv: maxLevel}
+	cfg.init(&lsl.levelGen)
+	lsl.head = &SkNode[K, V]{topLv: lsl.maxLv}
+	lsl.head.next = make([]atomic.Pointer[SkNode[K, V]], lsl.maxLv+1)
+	lsl.tail = &SkNode[K, V]{topLv: lsl.maxLv}
 	for i := range lsl.head.next {
 		lsl.head.next[i].Store(lsl.tail)
 	}
-	lsl.less = less
-	lsl.eq = eq
+	lsl.cmp = cmp
+	if cfg.reclaim {
+		lsl.rc = newReclaimer(lsl.recycle)
+		lsl.pools = make([]sync.Pool, lsl.maxLv+1)
+	}
 	return &lsl
 }
 
-func (lsl *Skiplist[K, V]) find(k K, preds, succs *[numLevel]*SkNode[K, V]) int {
+// NewOrderedSkipList creates a skiplist of cmp.Ordered keys.
+func NewOrderedSkipList[K cmp.Ordered, V any](opts ...SkiplistOption) *Skiplist[K, V] {
+	return NewSkipListFunc[K, V](cmp.Compare[K], opts...)
+}
+
+// NewUUIDSkipList creates a skiplist of uuid.UUID keys.
+func NewUUIDSkipList[V any](opts ...SkiplistOption) *Skiplist[uuid.UUID, V] {
+	return NewSkipListFunc[uuid.UUID, V](UUIDCmp, opts...)
+}
+
+// newNode creates a new node, from the pool if nodes are recycled.
+func (lsl *Skiplist[K, V]) newNode(k K, v V, lv int) *SkNode[K, V] {
+	if lsl.pools != nil {
+		if n, ok := lsl.pools[lv].Get().(*SkNode[K, V]); ok {
+			n.key, n.val = k, v
+			return n
+		}
+	}
+	return newNode(k, v, lv)
+}
+
+// recycle resets a retired node and puts it in the pool.
+func (lsl *Skiplist[K, V]) recycle(n *SkNode[K, V]) {
+	var zk K
+	var zv V
+	n.key, n.val = zk, zv
+	n.marked.Store(false)
+	n.fullyLinked.Store(false)
+	n.upd.Store(nil)
+	for i := range n.next {
+		n.next[i].Store(nil)
+	}
+	lsl.pools[n.topLv].Put(n)
+}
+
+// Pin keeps nodes from being recycled until the guard is unpinned.  It is
+// only needed to hold nodes from First and Next with WithReclamation.
+func (lsl *Skiplist[K, V]) Pin() EpochGuard {
+	return lsl.rc.pin()
+}
+
+// new node is created at a random level in [0, maxLv]
+// It is probalistic -- probablity (1-p)p^N at lv N.
+func (lg *levelGen) randomLv() int {
+	lv := 0
+	if lg.rng == nil {
+		for lv < lg.maxLv && rand.Float64() < lg.p {
+			lv++
+		}
+		return lv
+	}
+
+	lg.rngMu.Lock()
+	defer lg.rngMu.Unlock()
+	for lv < lg.maxLv && lg.rng.Float64() < lg.p {
+		lv++
+	}
+	return lv
+}
+
+func (lsl *Skiplist[K, V]) find(k K, preds, succs *[maxNumLevel]*SkNode[K, V]) int {
 	lvFound := -1
 	pred := lsl.head
 	// Search level top down to bottom.
-	for lv := maxLevel; lv >= 0; lv-- {
+	for lv := lsl.maxLv; lv >= 0; lv-- {
 		curr := pred.next[lv].Load()
-		for curr != lsl.tail && lsl.less(curr.key, k) {
+		c := 1
+		for curr != lsl.tail {
+			if c = lsl.cmp(curr.key, k); c >= 0 {
+				break
+			}
 			pred = curr
 			curr = pred.next[lv].Load()
 		}
 
-		if curr != lsl.tail && lsl.eq(curr.key, k) {
+		// record the top level where k is found.
+		if lvFound == -1 && curr != lsl.tail && c == 0 {
 			lvFound = lv
 		}
 		preds[lv] = pred
@@ -100,8 +292,20 @@ func (lsl *Skiplist[K, V]) find(k K, preds, succs *[numLevel]*SkNode[K, V]) int
 	return lvFound
 }
 
-func (lsl *Skiplist[K, V]) lockAdd(topLv int, k K, v V, preds, succs *[numLevel]*SkNode[K, V]) bool {
+// unlockPreds unlocks the preds locked for levels below lv.   A defer per
+// lock in the loops below would be heap allocated.
+func unlockPreds[K any, V any](preds *[maxNumLevel]*SkNode[K, V], lv int) {
+	for i := 0; i < lv; i++ {
+		if i == 0 || preds[i] != preds[i-1] {
+			preds[i].lock.Unlock()
+		}
+	}
+}
+
+func (lsl *Skiplist[K, V]) lockAdd(topLv int, k K, v V, preds, succs *[maxNumLevel]*SkNode[K, V]) bool {
 	var pred, succ *SkNode[K, V]
+	locked := 0
+	defer func() { unlockPreds(preds, locked) }()
 	// This loop, we acquire lock from bottom lv and up.   This is important
 	// for deadlock avoidance.
 	for lv := 0; lv <= topLv; lv++ {
@@ -110,8 +314,8 @@ func (lsl *Skiplist[K, V]) lockAdd(topLv int, k K, v V, preds, succs *[numLevel]
 		// our lock is not reentrant, poor man's solution
 		if lv == 0 || preds[lv] != preds[lv-1] {
 			pred.lock.Lock()
-			defer pred.lock.Unlock()
 		}
+		locked = lv + 1
 		// check valid, that is, pred and succ not marked and pred->next == succ
 		valid := pred.isNotMarked() && succ.isNotMarked() && pred.nextIs(lv, succ)
 		if !valid {
@@ -119,7 +323,7 @@ func (lsl *Skiplist[K, V]) lockAdd(topLv int, k K, v V, preds, succs *[numLevel]
 		}
 	}
 
-	nn := newNode(k, v, topLv)
+	nn := lsl.newNode(k, v, topLv)
 	for lv := 0; lv <= topLv; lv++ {
 		nn.next[lv].Store(succs[lv])
 	}
@@ -131,9 +335,10 @@ func (lsl *Skiplist[K, V]) lockAdd(topLv int, k K, v V, preds, succs *[numLevel]
 }
 
 func (lsl *Skiplist[K, V]) Add(k K, v V) bool {
-	topLv := randomLv()
-	var preds [numLevel]*SkNode[K, V]
-	var succs [numLevel]*SkNode[K, V]
+	defer lsl.rc.pin().Unpin()
+	topLv := lsl.randomLv()
+	var preds [maxNumLevel]*SkNode[K, V]
+	var succs [maxNumLevel]*SkNode[K, V]
 	for {
 		lvFound := lsl.find(k, &preds, &succs)
 		if lvFound >= 0 {
@@ -157,7 +362,50 @@ func (lsl *Skiplist[K, V]) Add(k K, v V) bool {
 	}
 }
 
-func (lsl *Skiplist[K, V]) lockRemove(victim *SkNode[K, V], preds, succs *[numLevel]*SkNode[K, V]) (bool, bool) {
+// Put sets the value of k, adding k if it is not in the list.   It
+// returns true if k was added.
+func (lsl *Skiplist[K, V]) Put(k K, v V) bool {
+	_, replaced := lsl.swap(k, v)
+	return !replaced
+}
+
+// swap is Put, returning the value replaced and true, or false if k was
+// added.
+func (lsl *Skiplist[K, V]) swap(k K, v V) (V, bool) {
+	defer lsl.rc.pin().Unpin()
+	topLv := lsl.randomLv()
+	var preds [maxNumLevel]*SkNode[K, V]
+	var succs [maxNumLevel]*SkNode[K, V]
+	for {
+		lvFound := lsl.find(k, &preds, &succs)
+		if lvFound >= 0 {
+			nodeFound := succs[lvFound]
+			if nodeFound.isNotMarked() {
+				for !nodeFound.fullyLinked.Load() {
+					// spin
+				}
+				// replace under the node lock, so that it is not lost to a
+				// concurrent remove.
+				nodeFound.lock.Lock()
+				if nodeFound.isNotMarked() {
+					old := nodeFound.GetV()
+					nodeFound.upd.Store(&v)
+					nodeFound.lock.Unlock()
+					return old, true
+				}
+				nodeFound.lock.Unlock()
+			}
+			// found a marked node, retry ...
+			continue
+		}
+		if lsl.lockAdd(topLv, k, v, &preds, &succs) {
+			var zero V
+			return zero, false
+		}
+	}
+}
+
+func (lsl *Skiplist[K, V]) lockRemove(victim *SkNode[K, V], pred func(V) bool, preds, succs *[maxNumLevel]*SkNode[K, V]) (bool, bool) {
 	// lock victim
 	victim.lock.Lock()
 	defer victim.lock.Unlock()
@@ -165,16 +413,22 @@ func (lsl *Skiplist[K, V]) lockRemove(victim *SkNode[K, V], preds, succs *[numLe
 		// Already marked for deletion
 		return true, false
 	}
+	// the value cannot change while victim is locked.
+	if pred != nil && !pred(victim.GetV()) {
+		return true, false
+	}
 
 	// lock preds, in asceding order, deadlock avoidance.
 	topLv := victim.topLv
+	locked := 0
+	defer func() { unlockPreds(preds, locked) }()
 	for lv := 0; lv <= topLv; lv++ {
 		pred := preds[lv]
 		// our lock is not reentrant, poor man's solution
 		if lv == 0 || preds[lv] != preds[lv-1] {
 			pred.lock.Lock()
-			defer pred.lock.Unlock()
 		}
+		locked = lv + 1
 		valid := pred.isNotMarked() && pred.nextIs(lv, victim)
 		if !valid {
 			return false, false
@@ -191,12 +445,22 @@ func (lsl *Skiplist[K, V]) lockRemove(victim *SkNode[K, V], preds, succs *[numLe
 }
 
 func (lsl *Skiplist[K, V]) Remove(k K) bool {
-	var preds [numLevel]*SkNode[K, V]
-	var succs [numLevel]*SkNode[K, V]
+	_, ok := lsl.removeIf(k, nil)
+	return ok
+}
+
+// removeIf removes k if pred, called with the value of k under the node
+// lock, returns true.  A nil pred always removes.   It returns the value
+// removed.
+func (lsl *Skiplist[K, V]) removeIf(k K, pred func(V) bool) (V, bool) {
+	defer lsl.rc.pin().Unpin()
+	var zero V
+	var preds [maxNumLevel]*SkNode[K, V]
+	var succs [maxNumLevel]*SkNode[K, V]
 	for {
 		lvFound := lsl.find(k, &preds, &succs)
 		if lvFound < 0 {
-			return false
+			return zero, false
 		}
 		victim := succs[lvFound]
 		// check if victim is ready to remove -- fully linked, not marked,
@@ -205,33 +469,98 @@ func (lsl *Skiplist[K, V]) Remove(k K) bool {
 		// concurrent remove
 		ready := victim.fullyLinked.Load() && (!victim.marked.Load()) && victim.topLv == lvFound
 		if !ready {
-			return false
+			return zero, false
 		}
 		// lock and remove.
-		valid, ret := lsl.lockRemove(victim, &preds, &succs)
+		valid, ret := lsl.lockRemove(victim, pred, &preds, &succs)
 		if !valid {
 			continue
 		}
-		return ret
+		if !ret {
+			return zero, false
+		}
+		// read before the node can be recycled.
+		v := victim.GetV()
+		if lsl.rc != nil {
+			lsl.rc.retire(victim)
+		}
+		return v, true
 	}
 }
 
 func (lsl *Skiplist[K, V]) Lookup(k K) (V, bool) {
+	defer lsl.rc.pin().Unpin()
 	pred := lsl.head
-	for lv := maxLevel; lv >= 0; lv-- {
+	for lv := lsl.maxLv; lv >= 0; lv-- {
 		curr := pred.next[lv].Load()
-		for curr != lsl.tail && lsl.less(curr.key, k) {
+		c := 1
+		for curr != lsl.tail {
+			if c = lsl.cmp(curr.key, k); c >= 0 {
+				break
+			}
 			pred = curr
 			curr = pred.next[lv].Load()
 		}
 
-		if curr != lsl.tail && lsl.eq(curr.key, k) {
-			return curr.val, !curr.marked.Load()
+		if curr != lsl.tail && c == 0 {
+			return curr.GetV(), !curr.marked.Load()
 		}
 	}
 	return lsl.head.val, false
 }
 
+// Contains returns if k is in the list.
+func (lsl *Skiplist[K, V]) Contains(k K) bool {
+	_, ok := lsl.Lookup(k)
+	return ok
+}
+
+// LookupMany looks up keys and returns their values, and if each key was
+// found.   Each search starts from the window of the previous one, the
+// lowest level where pred < k <= succ, so keys in ascending order share
+// most of their path.   Keys out of order restart from the head.   A key
+// there during the whole call is found, one added or removed concurrently
+// may or may not be.
+func (lsl *Skiplist[K, V]) LookupMany(keys []K) ([]V, []bool) {
+	defer lsl.rc.pin().Unpin()
+	vals := make([]V, len(keys))
+	found := make([]bool, len(keys))
+	var preds [maxNumLevel]*SkNode[K, V]
+	var succs [maxNumLevel]*SkNode[K, V]
+	for i, k := range keys {
+		lv, pred := lsl.maxLv, lsl.head
+		if i > 0 && lsl.cmp(keys[i-1], k) <= 0 {
+			// preds are all before k, climb to a window holding k.
+			for lv = 0; lv < lsl.maxLv; lv++ {
+				if succs[lv] == lsl.tail || lsl.cmp(succs[lv].key, k) >= 0 {
+					break
+				}
+			}
+			pred = preds[lv]
+		}
+
+		c := 1
+		var curr *SkNode[K, V]
+		for ; lv >= 0; lv-- {
+			curr = pred.next[lv].Load()
+			c = 1
+			for curr != lsl.tail {
+				if c = lsl.cmp(curr.key, k); c >= 0 {
+					break
+				}
+				pred = curr
+				curr = pred.next[lv].Load()
+			}
+			preds[lv] = pred
+			succs[lv] = curr
+		}
+		if curr != lsl.tail && c == 0 && curr.isNotMarked() {
+			vals[i], found[i] = curr.GetV(), true
+		}
+	}
+	return vals, found
+}
+
 func (lsl *Skiplist[K, V]) Next(curr *SkNode[K, V]) *SkNode[K, V] {
 	var next *SkNode[K, V]
 	if curr == nil {
@@ -254,3 +583,129 @@ func (lsl *Skiplist[K, V]) Next(curr *SkNode[K, V]) *SkNode[K, V] {
 func (lsl *Skiplist[K, V]) First() *SkNode[K, V] {
 	return lsl.Next(nil)
 }
+
+// All iterates the key value pairs in order, see Next.
+func (lsl *Skiplist[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		defer lsl.Pin().Unpin()
+		for n := lsl.First(); n != nil; n = lsl.Next(n) {
+			if !yield(n.key, n.GetV()) {
+				return
+			}
+		}
+	}
+}
+
+// from iterates the key value pairs from the first key >= k, in order.
+func (lsl *Skiplist[K, V]) from(k K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		defer lsl.Pin().Unpin()
+		var preds [maxNumLevel]*SkNode[K, V]
+		var succs [maxNumLevel]*SkNode[K, V]
+		lsl.find(k, &preds, &succs)
+		for n := succs[0]; n != lsl.tail; n = n.next[0].Load() {
+			if n.isNotMarked() && !yield(n.key, n.GetV()) {
+				return
+			}
+		}
+	}
+}
+
+// BuildSkiplistFromSorted builds a skiplist from key value pairs in
+// strictly ascending key order.  Nodes are linked level by level at the
+// end of the list, so it is O(n), without searching or locking.
+func BuildSkiplistFromSorted[K any, V any](less, eq func(a, b K) bool, seq iter.Seq2[K, V], opts ...SkiplistOption) (*Skiplist[K, V], error) {
+	return BuildSkiplistFromSortedFunc(cmpOf(less, eq), seq, opts...)
+}
+
+// BuildSkiplistFromSortedFunc is BuildSkiplistFromSorted with a three-way
+// comparator.
+func BuildSkiplistFromSortedFunc[K any, V any](cmp func(a, b K) int, seq iter.Seq2[K, V], opts ...SkiplistOption) (*Skiplist[K, V], error) {
+	lsl := NewSkipListFunc[K, V](cmp, opts...)
+	if err := lsl.appendSorted(seq); err != nil {
+		return nil, err
+	}
+	return lsl, nil
+}
+
+// appendSorted appends key value pairs in strictly ascending order, all
+// greater than keys already in the list.   Not safe with concurrent writers.
+func (lsl *Skiplist[K, V]) appendSorted(seq iter.Seq2[K, V]) error {
+	// last node of each level.
+	var last [maxNumLevel]*SkNode[K, V]
+	pred := lsl.head
+	for lv := lsl.maxLv; lv >= 0; lv-- {
+		for curr := pred.next[lv].Load(); curr != lsl.tail; curr = curr.next[lv].Load() {
+			pred = curr
+		}
+		last[lv] = pred
+	}
+
+	for k, v := range seq {
+		if last[0] != lsl.head && lsl.cmp(last[0].key, k) >= 0 {
+			return fmt.Errorf("skiplist input is not sorted")
+		}
+		topLv := lsl.randomLv()
+		nn := lsl.newNode(k, v, topLv)
+		for lv := 0; lv <= topLv; lv++ {
+			nn.next[lv].Store(lsl.tail)
+			last[lv].next[lv].Store(nn)
+			last[lv] = nn
+		}
+		nn.fullyLinked.Store(true)
+	}
+	return nil
+}
+
+// Merge adds all keys of other into the list.   For keys in both lists,
+// conflict returns the value to keep, if conflict is nil the value in
+// lsl is kept.   Merge walks both lists once, resuming each search where
+// the previous key was found.  It must not run concurrently with any
+// other operation on lsl or other.
+func (lsl *Skiplist[K, V]) Merge(other *Skiplist[K, V], conflict func(k K, mine, theirs V) V) {
+	var preds [maxNumLevel]*SkNode[K, V]
+	var succs [maxNumLevel]*SkNode[K, V]
+	for lv := range preds {
+		preds[lv] = lsl.head
+	}
+
+	for n := other.First(); n != nil; n = other.Next(n) {
+		// preds of the previous key are still before this key, start each
+		// level from the one that is further along.
+		pred := lsl.head
+		c := 1
+		for lv := lsl.maxLv; lv >= 0; lv-- {
+			if p := preds[lv]; p != lsl.head && (pred == lsl.head || lsl.cmp(pred.key, p.key) < 0) {
+				pred = p
+			}
+			curr := pred.next[lv].Load()
+			c = 1
+			for curr != lsl.tail {
+				if c = lsl.cmp(curr.key, n.key); c >= 0 {
+					break
+				}
+				pred = curr
+				curr = pred.next[lv].Load()
+			}
+			preds[lv] = pred
+			succs[lv] = curr
+		}
+
+		if succs[0] != lsl.tail && c == 0 {
+			if conflict != nil {
+				v := conflict(n.key, succs[0].GetV(), n.GetV())
+				succs[0].upd.Store(&v)
+			}
+			continue
+		}
+
+		topLv := lsl.randomLv()
+		nn := lsl.newNode(n.key, n.GetV(), topLv)
+		for lv := 0; lv <= topLv; lv++ {
+			nn.next[lv].Store(succs[lv])
+			preds[lv].next[lv].Store(nn)
+			preds[lv] = nn
+		}
+		nn.fullyLinked.Store(true)
+	}
+}
@@ -1,16 +1,25 @@
 package gcl
 
 import (
+	"cmp"
+	"fmt"
 	"math/rand"
+	"slices"
 	"sync"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestRandomLv(t *testing.T) {
 	var cnt [numLevel]int
+	list := NewSkipList[int, int](
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+	)
 	N := 1000000
 	for i := 0; i < N; i++ {
-		lv := randomLv()
+		lv := list.randomLv()
 		cnt[lv] += 1
 	}
 	if cnt[0] <= N/2 {
@@ -95,3 +104,330 @@ func TestLazySkipList(t *testing.T) {
 		t.Errorf("counting error %d %d %d", remCnt, cnt, insCnt)
 	}
 }
+
+func TestSkiplistOptions(t *testing.T) {
+	list := NewSkipList[int, int](
+		func(a, b int) bool { return a < b },
+		func(a, b int) bool { return a == b },
+		WithMaxLevel(20), WithProbability(0.5),
+	)
+	if list.maxLv != 19 || list.p != 0.5 {
+		t.Fatalf("options not applied, maxLv %d, p %f", list.maxLv, list.p)
+	}
+
+	var cnt [20]int
+	for i := 0; i < 100000; i++ {
+		lv := list.randomLv()
+		if lv > list.maxLv {
+			t.Fatalf("random level %d above max level %d", lv, list.maxLv)
+		}
+		cnt[lv]++
+	}
+	if cnt[10] == 0 {
+		t.Errorf("p = 1/2 should reach level 10, got %v", cnt)
+	}
+
+	for i := 0; i < 1000; i++ {
+		list.Add(i, i*2)
+	}
+	for i := 0; i < 1000; i++ {
+		if v, ok := list.Lookup(i); !ok || v != i*2 {
+			t.Fatalf("lookup %d got %d, %v", i, v, ok)
+		}
+	}
+
+	big := NewSkipList[int, int](nil, nil, WithExpectedSize(10000000))
+	if big.maxLv+1 != 12 {
+		t.Errorf("expected size 10M should use 12 levels, got %d", big.maxLv+1)
+	}
+
+	// WithMaxLevel is a maximum, in either order.
+	for _, opts := range [][]SkiplistOption{
+		{WithMaxLevel(4), WithExpectedSize(10000000)},
+		{WithExpectedSize(10000000), WithMaxLevel(4)},
+	} {
+		if l := NewSkipList[int, int](nil, nil, opts...); l.maxLv != 3 {
+			t.Errorf("max level 4 with expected size 10M got %d levels", l.maxLv+1)
+		}
+	}
+}
+
+// TestSkiplistRemoveTall removes nodes of every level, find used to
+// report the lowest level a key was found at, and Remove failed for nodes
+// above level 0.
+func TestSkiplistRemoveTall(t *testing.T) {
+	list := NewOrderedSkipList[int, int](WithSeed(1))
+	for i := 0; i < 1000; i++ {
+		list.Add(i, i)
+	}
+	tall := 0
+	for n := list.First(); n != nil; n = list.Next(n) {
+		if n.topLv > 0 {
+			tall++
+		}
+	}
+	if tall == 0 {
+		t.Fatalf("no node above level 0")
+	}
+	for i := 0; i < 1000; i++ {
+		if !list.Remove(i) {
+			t.Fatalf("remove %d failed", i)
+		}
+		if _, ok := list.Lookup(i); ok {
+			t.Fatalf("lookup %d after remove", i)
+		}
+	}
+	if list.First() != nil {
+		t.Errorf("list not empty")
+	}
+}
+
+func TestSkiplistSeed(t *testing.T) {
+	shape := func(seed uint64) []int {
+		list := NewSkipList[int, int](
+			func(a, b int) bool { return a < b },
+			func(a, b int) bool { return a == b },
+			WithSeed(seed),
+		)
+		for i := 0; i < 1000; i++ {
+			list.Add((i*7919)%1000, i)
+		}
+		var lvs []int
+		for n := list.First(); n != nil; n = list.Next(n) {
+			lvs = append(lvs, n.topLv)
+		}
+		return lvs
+	}
+
+	a, b, c := shape(42), shape(42), shape(43)
+	check(t, a, b)
+	same := true
+	for i := range a {
+		same = same && a[i] == c[i]
+	}
+	if same {
+		t.Errorf("different seeds built the same skiplist")
+	}
+}
+
+func intSeq(keys ...int) func(yield func(int, int) bool) {
+	return func(yield func(int, int) bool) {
+		for _, k := range keys {
+			if !yield(k, k*10) {
+				return
+			}
+		}
+	}
+}
+
+func skKeys(list *Skiplist[int, int]) []int {
+	var keys []int
+	for n := list.First(); n != nil; n = list.Next(n) {
+		keys = append(keys, n.GetK())
+	}
+	return keys
+}
+
+func TestSkiplistBuildMerge(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	eq := func(a, b int) bool { return a == b }
+
+	if _, err := BuildSkiplistFromSorted(less, eq, intSeq(1, 3, 3)); err == nil {
+		t.Errorf("build should fail on duplicate keys")
+	}
+
+	var keys []int
+	for i := 0; i < 1000; i += 2 {
+		keys = append(keys, i)
+	}
+	list := Must(BuildSkiplistFromSorted(less, eq, intSeq(keys...), WithSeed(1)))
+	check(t, keys, skKeys(list))
+	for _, k := range keys {
+		if v, ok := list.Lookup(k); !ok || v != k*10 {
+			t.Fatalf("lookup %d got %d, %v", k, v, ok)
+		}
+	}
+
+	other := Must(BuildSkiplistFromSorted(less, eq, intSeq(-1, 3, 4, 999, 1001)))
+	list.Merge(other, func(k int, mine, theirs int) int { return mine + theirs })
+	keys = append(keys, -1, 3, 999, 1001)
+	slices.Sort(keys)
+	check(t, keys, skKeys(list))
+	if v, _ := list.Lookup(4); v != 80 {
+		t.Errorf("merge conflict got %d, want 80", v)
+	}
+
+	// the merged list is still a valid skiplist.
+	for _, k := range keys {
+		if !list.Remove(k) {
+			t.Fatalf("cannot remove %d", k)
+		}
+	}
+	if list.First() != nil {
+		t.Errorf("list should be empty")
+	}
+}
+
+func BenchmarkSkiplistBuild(b *testing.B) {
+	const n = 1000000
+	less := func(a, b int) bool { return a < b }
+	eq := func(a, b int) bool { return a == b }
+	keys := make([]int, n)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	b.Run("add", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			list := NewSkipList[int, int](less, eq, WithExpectedSize(n))
+			for _, k := range keys {
+				list.Add(k, k)
+			}
+		}
+	})
+	b.Run("sorted", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			BuildSkiplistFromSorted(less, eq, intSeq(keys...), WithExpectedSize(n))
+		}
+	})
+}
+
+// BenchmarkSkiplistLookup shows lookup cost with 1M and 10M keys, cmps/op
+// is the number of comparator calls per lookup, it should grow with log(n).
+func BenchmarkSkiplistLookup(b *testing.B) {
+	for _, n := range []int{1000000, 10000000} {
+		for _, cfg := range []struct {
+			name string
+			opts []SkiplistOption
+		}{
+			{"default", nil},
+			{"sized", []SkiplistOption{WithExpectedSize(n)}},
+		} {
+			b.Run(fmt.Sprintf("%s-%d", cfg.name, n), func(b *testing.B) {
+				var cmps int
+				list := NewSkipList[int, int](
+					func(a, b int) bool { cmps++; return a < b },
+					func(a, b int) bool { cmps++; return a == b },
+					cfg.opts...,
+				)
+				for i := 0; i < n; i++ {
+					list.Add(i, i)
+				}
+				r := rand.New(rand.NewSource(1))
+				cmps = 0
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					list.Lookup(r.Intn(n))
+				}
+				b.ReportMetric(float64(cmps)/float64(b.N), "cmps/op")
+			})
+		}
+	}
+}
+
+func TestSkiplistCmp(t *testing.T) {
+	var calls int
+	list := NewSkipListFunc[int, int](func(a, b int) int {
+		calls++
+		return cmp.Compare(a, b)
+	}, WithSeed(7))
+	for i := 0; i < 1000; i++ {
+		list.Add(i, i)
+	}
+
+	// with p = 1/4 a lookup visits about 4 nodes per level, each with a
+	// single comparator call.
+	calls = 0
+	list.Lookup(500)
+	if calls > 4*(list.maxLv+1) {
+		t.Errorf("lookup made %d comparator calls", calls)
+	}
+
+	ol := NewOrderedSkipList[string, int]()
+	ol.Add("b", 2)
+	ol.Add("a", 1)
+	if n := ol.First(); n.GetK() != "a" {
+		t.Errorf("ordered skiplist first is %s", n.GetK())
+	}
+
+	ul := NewUUIDSkipList[int]()
+	id := uuid.New()
+	ul.Add(id, 1)
+	if _, ok := ul.Lookup(id); !ok {
+		t.Errorf("uuid lookup failed")
+	}
+}
+
+func TestSkiplistPut(t *testing.T) {
+	list := NewOrderedSkipList[int, int](WithReclamation())
+	if !list.Put(1, 1) || list.Put(1, 2) {
+		t.Errorf("put should add then replace")
+	}
+	if v, ok := list.Lookup(1); !ok || v != 2 {
+		t.Errorf("lookup after put got %d, %v", v, ok)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if j%3 == 0 {
+					list.Remove(j % 10)
+				} else {
+					list.Put(j%10, j%10)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for k, v := range list.All() {
+		if k != v && !(k == 1 && v == 2) {
+			t.Errorf("key %d has value %d", k, v)
+		}
+	}
+}
+
+func TestSkiplistLookupMany(t *testing.T) {
+	var calls int
+	list := NewSkipListFunc[int, int](func(a, b int) int {
+		calls++
+		return cmp.Compare(a, b)
+	}, WithSeed(3))
+	for i := 0; i < 10000; i += 2 {
+		list.Add(i, i*10)
+	}
+	// a dense batch, as the keys of one request.
+	keys := make([]int, 500)
+	for i := range keys {
+		keys[i] = 4000 + rand.Intn(2000)
+	}
+	keys = append(keys, keys[0], -1, 20000)
+
+	check := func(keys []int) int {
+		calls = 0
+		vals, found := list.LookupMany(keys)
+		n := calls
+		for i, k := range keys {
+			if v, ok := list.Lookup(k); ok != found[i] || v != vals[i] {
+				t.Fatalf("key %d got %d, %v, want %d, %v", k, vals[i], found[i], v, ok)
+			}
+		}
+		return n
+	}
+	check(keys)
+	slices.Sort(keys)
+	many := check(keys)
+
+	calls = 0
+	for _, k := range keys {
+		list.Lookup(k)
+	}
+	if many*2 > calls {
+		t.Errorf("sorted LookupMany made %d comparator calls, Lookup %d", many, calls)
+	}
+	if !list.Contains(2) || list.Contains(3) {
+		t.Errorf("contains")
+	}
+}
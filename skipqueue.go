@@ -0,0 +1,99 @@
+package gcl
+
+import (
+	"sync/atomic"
+)
+
+//
+// Concurrent priority queue, see the SkipQueue described in
+// The Art of Multiprocessor Programming, Chapter 15
+//
+// Items are kept in a LockFreeSkiplist, keyed by priority and a sequence
+// number so that equal priorities are allowed and pop in push order.
+// PopMin walks the bottom level from the head and claims the first item
+// not claimed yet with a CAS, then removes it.   Pops never lock, and
+// concurrent pops claim different items.
+//
+// The queue is quiescently consistent, not linearizable: an item pushed
+// while a PopMin is past its position is not seen by that PopMin, which
+// may return a larger item instead.
+//
+
+type pqKey[K any] struct {
+	pri K
+	seq uint64
+}
+
+type pqItem[V any] struct {
+	val     V
+	claimed atomic.Bool
+}
+
+type SkipPriorityQueue[K any, V any] struct {
+	sl  *LockFreeSkiplist[pqKey[K], *pqItem[V]]
+	seq atomic.Uint64
+	cnt atomic.Int64
+}
+
+// NewSkipPriorityQueue creates a priority queue ordered by less, with eq
+// for equality.
+func NewSkipPriorityQueue[K any, V any](less, eq func(a, b K) bool, opts ...SkiplistOption) *SkipPriorityQueue[K, V] {
+	return NewSkipPriorityQueueFunc[K, V](cmpOf(less, eq), opts...)
+}
+
+// NewSkipPriorityQueueFunc creates a priority queue ordered by the
+// three-way comparator cmp, it takes the same options as NewSkipList.
+func NewSkipPriorityQueueFunc[K any, V any](cmp func(a, b K) int, opts ...SkiplistOption) *SkipPriorityQueue[K, V] {
+	keyCmp := func(a, b pqKey[K]) int {
+		if c := cmp(a.pri, b.pri); c != 0 {
+			return c
+		}
+		switch {
+		case a.seq < b.seq:
+			return -1
+		case a.seq > b.seq:
+			return 1
+		}
+		return 0
+	}
+	return &SkipPriorityQueue[K, V]{
+		sl: NewLockFreeSkiplistFunc[pqKey[K], *pqItem[V]](keyCmp, opts...),
+	}
+}
+
+// Push adds v with priority pri.
+func (q *SkipPriorityQueue[K, V]) Push(pri K, v V) {
+	// count first, so that a racing pop never takes it negative.
+	q.cnt.Add(1)
+	q.sl.Add(pqKey[K]{pri, q.seq.Add(1)}, &pqItem[V]{val: v})
+}
+
+// PopMin removes and returns an item of the smallest priority, ok is
+// false if the queue is empty.
+func (q *SkipPriorityQueue[K, V]) PopMin() (pri K, v V, ok bool) {
+	for n := q.sl.First(); n != nil; n = q.sl.Next(n) {
+		it := n.GetV()
+		if it.claimed.CompareAndSwap(false, true) {
+			q.sl.Remove(n.GetK())
+			q.cnt.Add(-1)
+			return n.GetK().pri, it.val, true
+		}
+	}
+	return pri, v, false
+}
+
+// PeekMin returns an item of the smallest priority without removing it.
+func (q *SkipPriorityQueue[K, V]) PeekMin() (pri K, v V, ok bool) {
+	for n := q.sl.First(); n != nil; n = q.sl.Next(n) {
+		if it := n.GetV(); !it.claimed.Load() {
+			return n.GetK().pri, it.val, true
+		}
+	}
+	return pri, v, false
+}
+
+// Len returns the number of items, it is exact when there are no
+// concurrent Push or PopMin.
+func (q *SkipPriorityQueue[K, V]) Len() int {
+	return int(q.cnt.Load())
+}
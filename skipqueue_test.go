@@ -0,0 +1,145 @@
+package gcl
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSkipPriorityQueue(t *testing.T) {
+	q := NewSkipPriorityQueueFunc[int, string](cmp.Compare[int])
+	if _, _, ok := q.PopMin(); ok {
+		t.Errorf("pop from empty queue")
+	}
+	q.Push(3, "c")
+	q.Push(1, "a1")
+	q.Push(2, "b")
+	q.Push(1, "a2")
+	if p, v, ok := q.PeekMin(); !ok || p != 1 || v != "a1" || q.Len() != 4 {
+		t.Errorf("peek got %d %s %v, len %d", p, v, ok, q.Len())
+	}
+	// equal priorities pop in push order.
+	var got []string
+	for {
+		_, v, ok := q.PopMin()
+		if !ok {
+			break
+		}
+		got = append(got, v)
+	}
+	if fmt.Sprint(got) != "[a1 a2 b c]" || q.Len() != 0 {
+		t.Errorf("popped %v, len %d", got, q.Len())
+	}
+}
+
+func TestSkipPriorityQueueConcurrent(t *testing.T) {
+	const (
+		thCnt   = 8
+		loopCnt = 1000
+	)
+	q := NewSkipPriorityQueueFunc[int, int](cmp.Compare[int])
+	var wg sync.WaitGroup
+	popped := make([][]int, thCnt)
+	for i := 0; i < thCnt; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				q.Push(j%10, i*loopCnt+j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				if _, v, ok := q.PopMin(); ok {
+					popped[i] = append(popped[i], v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for {
+		_, v, ok := q.PopMin()
+		if !ok {
+			break
+		}
+		popped[0] = append(popped[0], v)
+	}
+
+	// every item is popped exactly once.
+	seen := make([]bool, thCnt*loopCnt)
+	for _, vs := range popped {
+		for _, v := range vs {
+			if seen[v] {
+				t.Fatalf("item %d popped twice", v)
+			}
+			seen[v] = true
+		}
+	}
+	for v, ok := range seen {
+		if !ok {
+			t.Fatalf("item %d lost", v)
+		}
+	}
+	if q.Len() != 0 {
+		t.Errorf("len %d after draining", q.Len())
+	}
+}
+
+// intHeap is a container/heap of ints.
+type intHeap []int
+
+func (h intHeap) Len() int           { return len(h) }
+func (h intHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type mutexHeap struct {
+	sync.Mutex
+	h intHeap
+}
+
+func BenchmarkPriorityQueue(b *testing.B) {
+	const initCnt = 10000
+	b.Run("skipqueue", func(b *testing.B) {
+		q := NewSkipPriorityQueueFunc[int, int](cmp.Compare[int])
+		for i := 0; i < initCnt; i++ {
+			q.Push(rand.Intn(initCnt), i)
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				q.Push(r.Intn(initCnt), 0)
+				q.PopMin()
+			}
+		})
+	})
+	b.Run("heap-mutex", func(b *testing.B) {
+		var q mutexHeap
+		for i := 0; i < initCnt; i++ {
+			heap.Push(&q.h, rand.Intn(initCnt))
+		}
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			r := rand.New(rand.NewSource(rand.Int63()))
+			for pb.Next() {
+				q.Lock()
+				heap.Push(&q.h, r.Intn(initCnt))
+				q.Unlock()
+				q.Lock()
+				heap.Pop(&q.h)
+				q.Unlock()
+			}
+		})
+	})
+}
@@ -0,0 +1,284 @@
+package gcl
+
+import (
+	"cmp"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//
+// Skiplist with expiring entries.   Each key of the underlying Skiplist
+// holds an entry with its deadline.   Expired entries are absent to
+// Lookup and All, and are removed lazily when Lookup or Add run into
+// them, or by Sweep, which can run periodically in the background.
+//
+// Entries that expire are also in a second skiplist ordered by deadline,
+// so Sweep visits the expired entries only.   An entry is added to it
+// after the list, and removed after it is removed from the list, so the
+// index may briefly hold an entry no longer in the list, never the other
+// way round.
+//
+// An entry is removed at most once, with the node lock held on its exact
+// value, so the eviction callback is called once per expired entry and
+// never for an entry that replaced it.
+//
+
+type ttlEntry[V any] struct {
+	val V
+	// deadline in unix nanoseconds, 0 never expires.
+	expire int64
+	// unique, orders entries of the same deadline.
+	seq uint64
+}
+
+// ttlDeadline is the key of an entry in the deadline index.
+type ttlDeadline struct {
+	expire int64
+	seq    uint64
+}
+
+func ttlDeadlineCmp(a, b ttlDeadline) int {
+	if c := cmp.Compare(a.expire, b.expire); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.seq, b.seq)
+}
+
+// TTLOption configures a TTLSkiplist.
+type TTLOption func(*ttlConfig)
+
+type ttlConfig struct {
+	now      func() time.Time
+	sweep    time.Duration
+	listOpts []SkiplistOption
+}
+
+// WithClock sets the clock used for deadlines, default is time.Now.
+func WithClock(now func() time.Time) TTLOption {
+	return func(c *ttlConfig) {
+		c.now = now
+	}
+}
+
+// WithSweepInterval runs Sweep in the background every d, until Close.
+func WithSweepInterval(d time.Duration) TTLOption {
+	return func(c *ttlConfig) {
+		MustCheck(d > 0, "sweep interval must be positive")
+		c.sweep = d
+	}
+}
+
+// WithTTLSkiplistOptions passes options to the underlying Skiplist.
+func WithTTLSkiplistOptions(opts ...SkiplistOption) TTLOption {
+	return func(c *ttlConfig) {
+		c.listOpts = append(c.listOpts, opts...)
+	}
+}
+
+type TTLSkiplist[K any, V any] struct {
+	sl *Skiplist[K, *ttlEntry[V]]
+	// keys of the entries that expire, by deadline.
+	idx *Skiplist[ttlDeadline, K]
+	seq atomic.Uint64
+	now func() time.Time
+	// called with each expired entry removed.
+	onEvict func(k K, v V)
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewTTLSkiplist creates a skiplist of expiring entries, ordered by less,
+// with eq for equality.   onEvict, if not nil, is called with each expired
+// entry when it is removed.
+func NewTTLSkiplist[K any, V any](less, eq func(a, b K) bool, onEvict func(k K, v V), opts ...TTLOption) *TTLSkiplist[K, V] {
+	return NewTTLSkiplistFunc(cmpOf(less, eq), onEvict, opts...)
+}
+
+// NewTTLSkiplistFunc is NewTTLSkiplist with a three-way comparator.
+func NewTTLSkiplistFunc[K any, V any](cmp func(a, b K) int, onEvict func(k K, v V), opts ...TTLOption) *TTLSkiplist[K, V] {
+	cfg := ttlConfig{now: time.Now}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	t := &TTLSkiplist[K, V]{
+		sl:      NewSkipListFunc[K, *ttlEntry[V]](cmp, cfg.listOpts...),
+		idx:     NewSkipListFunc[ttlDeadline, K](ttlDeadlineCmp),
+		now:     cfg.now,
+		onEvict: onEvict,
+		done:    make(chan struct{}),
+	}
+	if cfg.sweep > 0 {
+		t.wg.Add(1)
+		go t.sweeper(cfg.sweep)
+	}
+	return t
+}
+
+func (t *TTLSkiplist[K, V]) entry(v V, ttl time.Duration) *ttlEntry[V] {
+	e := &ttlEntry[V]{val: v, seq: t.seq.Add(1)}
+	if ttl > 0 {
+		e.expire = t.now().Add(ttl).UnixNano()
+	}
+	return e
+}
+
+func (e *ttlEntry[V]) expired(now int64) bool {
+	return e.expire != 0 && e.expire <= now
+}
+
+// index adds e of k, now in the list, to the deadline index.
+func (t *TTLSkiplist[K, V]) index(k K, e *ttlEntry[V]) {
+	if e.expire != 0 {
+		t.idx.Add(ttlDeadline{e.expire, e.seq}, k)
+	}
+}
+
+// unindex removes e, no longer in the list, from the deadline index.
+func (t *TTLSkiplist[K, V]) unindex(e *ttlEntry[V]) {
+	if e.expire != 0 {
+		t.idx.Remove(ttlDeadline{e.expire, e.seq})
+	}
+}
+
+// evict removes the expired entry e of k, unless someone else did.
+func (t *TTLSkiplist[K, V]) evict(k K, e *ttlEntry[V]) {
+	_, ok := t.sl.removeIf(k, func(cur *ttlEntry[V]) bool { return cur == e })
+	if ok {
+		t.unindex(e)
+		if t.onEvict != nil {
+			t.onEvict(k, e.val)
+		}
+	}
+}
+
+// Add adds k with value v, expiring after ttl, ttl <= 0 never expires.
+// It returns false if k is there and not expired.
+func (t *TTLSkiplist[K, V]) Add(k K, v V, ttl time.Duration) bool {
+	e := t.entry(v, ttl)
+	for {
+		if t.sl.Add(k, e) {
+			t.index(k, e)
+			return true
+		}
+		old, ok := t.sl.Lookup(k)
+		if !ok {
+			// removed since, retry.
+			continue
+		}
+		if !old.expired(t.now().UnixNano()) {
+			return false
+		}
+		t.evict(k, old)
+	}
+}
+
+// Put sets the value and ttl of k, adding k if it is not there.   An
+// expired entry replaced by Put is not passed to the eviction callback.
+func (t *TTLSkiplist[K, V]) Put(k K, v V, ttl time.Duration) {
+	e := t.entry(v, ttl)
+	old, replaced := t.sl.swap(k, e)
+	t.index(k, e)
+	if replaced {
+		t.unindex(old)
+	}
+}
+
+// Remove removes k, it returns false if k is not there or expired.
+func (t *TTLSkiplist[K, V]) Remove(k K) bool {
+	e, ok := t.sl.removeIf(k, nil)
+	if ok {
+		t.unindex(e)
+	}
+	if ok && e.expired(t.now().UnixNano()) {
+		if t.onEvict != nil {
+			t.onEvict(k, e.val)
+		}
+		return false
+	}
+	return ok
+}
+
+// Lookup returns the value of k, if k is there and not expired.
+func (t *TTLSkiplist[K, V]) Lookup(k K) (V, bool) {
+	v, _, ok := t.LookupDeadline(k)
+	return v, ok
+}
+
+// LookupDeadline is Lookup, also returning when k expires, the zero time
+// if never.
+func (t *TTLSkiplist[K, V]) LookupDeadline(k K) (V, time.Time, bool) {
+	var zero V
+	e, ok := t.sl.Lookup(k)
+	if !ok {
+		return zero, time.Time{}, false
+	}
+	if e.expired(t.now().UnixNano()) {
+		t.evict(k, e)
+		return zero, time.Time{}, false
+	}
+	if e.expire == 0 {
+		return e.val, time.Time{}, true
+	}
+	return e.val, time.Unix(0, e.expire), true
+}
+
+// All iterates the key value pairs not expired, in order.   Expired
+// entries are skipped, not removed.
+func (t *TTLSkiplist[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		now := t.now().UnixNano()
+		for k, e := range t.sl.All() {
+			if !e.expired(now) && !yield(k, e.val) {
+				return
+			}
+		}
+	}
+}
+
+// Sweep removes all expired entries and returns how many it removed.
+// It visits the expired entries only, in deadline order.
+func (t *TTLSkiplist[K, V]) Sweep() int {
+	cnt := 0
+	now := t.now().UnixNano()
+	for n := t.idx.First(); n != nil; n = t.idx.First() {
+		d, k := n.GetK(), n.GetV()
+		if d.expire > now {
+			break
+		}
+		e, ok := t.sl.removeIf(k, func(cur *ttlEntry[V]) bool { return cur.seq == d.seq })
+		// removed by someone else if not ok, who unindexes it too.
+		t.idx.Remove(d)
+		if ok {
+			cnt++
+			if t.onEvict != nil {
+				t.onEvict(k, e.val)
+			}
+		}
+	}
+	return cnt
+}
+
+func (t *TTLSkiplist[K, V]) sweeper(d time.Duration) {
+	defer t.wg.Done()
+	tick := time.NewTicker(d)
+	defer tick.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-tick.C:
+			t.Sweep()
+		}
+	}
+}
+
+// Close stops the background sweeper.   Closing a closed list does
+// nothing.
+func (t *TTLSkiplist[K, V]) Close() {
+	t.closeOnce.Do(func() { close(t.done) })
+	t.wg.Wait()
+}
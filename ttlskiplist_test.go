@@ -0,0 +1,151 @@
+package gcl
+
+import (
+	"cmp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeClock is a clock advanced by hand.
+type fakeClock struct{ ns atomic.Int64 }
+
+func (c *fakeClock) now() time.Time          { return time.Unix(0, c.ns.Load()) }
+func (c *fakeClock) advance(d time.Duration) { c.ns.Add(int64(d)) }
+
+func TestTTLSkiplist(t *testing.T) {
+	var clock fakeClock
+	clock.ns.Store(time.Now().UnixNano())
+	evicted := make(map[int]int)
+	var mu sync.Mutex
+	list := NewTTLSkiplistFunc(cmp.Compare[int], func(k, v int) {
+		mu.Lock()
+		defer mu.Unlock()
+		evicted[k]++
+	}, WithClock(clock.now))
+	defer list.Close()
+
+	for i := 0; i < 10; i++ {
+		list.Add(i, i, time.Duration(i)*time.Second)
+	}
+	if _, dl, ok := list.LookupDeadline(5); !ok || !dl.Equal(clock.now().Add(5*time.Second)) {
+		t.Errorf("deadline of 5 is %v, %v", dl, ok)
+	}
+	clock.advance(3 * time.Second)
+
+	// 0 never expires, 1 to 3 are expired.
+	if _, ok := list.Lookup(2); ok {
+		t.Errorf("expired key found")
+	}
+	if v, ok := list.Lookup(0); !ok || v != 0 {
+		t.Errorf("key without ttl not found")
+	}
+	if list.Remove(3) {
+		t.Errorf("removed expired key")
+	}
+	if !list.Add(1, 10, 0) || list.Add(4, 40, 0) {
+		t.Errorf("add should replace only expired keys")
+	}
+	list.Put(4, 40, time.Hour)
+	clock.advance(3 * time.Second)
+
+	var keys []int
+	for k := range list.All() {
+		keys = append(keys, k)
+	}
+	if len(keys) != 6 || keys[1] != 1 || keys[2] != 4 || keys[3] != 7 {
+		t.Errorf("live keys %v", keys)
+	}
+
+	// racing sweeps and lookups evict each entry once.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			list.Sweep()
+			for k := 0; k < 10; k++ {
+				list.Lookup(k)
+			}
+		}()
+	}
+	wg.Wait()
+	// 1 was replaced by Add, the others expired, 6 right at its deadline.
+	for k, n := range map[int]int{1: 1, 2: 1, 3: 1, 5: 1, 6: 1} {
+		if evicted[k] != n {
+			t.Errorf("key %d evicted %d times, want %d", k, evicted[k], n)
+		}
+	}
+	if len(evicted) != 5 {
+		t.Errorf("evicted %v", evicted)
+	}
+}
+
+// TestTTLSkiplistSweepIndex checks Sweep goes by the deadline index, not
+// through the live keys, and the index holds the entries of the list.
+func TestTTLSkiplistSweepIndex(t *testing.T) {
+	var clock fakeClock
+	clock.ns.Store(time.Now().UnixNano())
+	list := NewTTLSkiplistFunc[int, int](cmp.Compare[int], nil, WithClock(clock.now))
+	defer list.Close()
+	indexed := func() int {
+		cnt := 0
+		for range list.idx.All() {
+			cnt++
+		}
+		return cnt
+	}
+
+	const n = 1000
+	for i := 0; i < n; i++ {
+		list.Add(i, i, time.Hour)
+	}
+	for i := 0; i < 100; i++ {
+		list.Put(0, i, time.Hour)
+	}
+	list.Remove(1)
+	list.Put(2, 2, 0)
+	if cnt := indexed(); cnt != n-2 {
+		t.Errorf("%d entries indexed, want %d", cnt, n-2)
+	}
+
+	// an expired entry not in the index is not found, a sweep does not
+	// look at the list.
+	list.sl.Put(n, &ttlEntry[int]{expire: clock.now().UnixNano()})
+	clock.advance(time.Minute)
+	if cnt := list.Sweep(); cnt != 0 {
+		t.Errorf("swept %d entries, none expired", cnt)
+	}
+	clock.advance(time.Hour)
+	if cnt := list.Sweep(); cnt != n-2 || indexed() != 0 {
+		t.Errorf("swept %d entries, %d left indexed", cnt, indexed())
+	}
+}
+
+func TestTTLSkiplistSweeper(t *testing.T) {
+	evicted := make(chan uuid.UUID, 10)
+	list := NewTTLSkiplistFunc(UUIDCmp, func(k uuid.UUID, v string) {
+		evicted <- k
+	}, WithSweepInterval(time.Millisecond))
+	defer list.Close()
+
+	id := uuid.New()
+	list.Add(id, "session", time.Millisecond)
+	list.Add(uuid.New(), "lease", time.Hour)
+	select {
+	case k := <-evicted:
+		if k != id {
+			t.Errorf("evicted %v, want %v", k, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("sweeper did not evict")
+	}
+	if n := list.Sweep(); n != 0 {
+		t.Errorf("second sweep removed %d", n)
+	}
+	// closed twice, here and deferred.
+	list.Close()
+}
@@ -0,0 +1,580 @@
+package gcl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+//
+// DurableSkiplist is a Skiplist whose updates are appended to a write
+// ahead log in a directory, so it can be reopened after a restart or a
+// crash.   The directory holds
+//
+//	snap-<seq>	the list saved with SaveTo, see codec.go
+//	wal-<seq>.log	log records, applied after snap-<seq> and older logs
+//
+// A log record is
+//
+//	uint32 payload length, uint32 crc32c of payload, payload
+//	payload: op, uvarint key length, key [, uvarint value length, value]
+//
+// Set records are replayed as Put and del records as Remove, both are
+// idempotent.   Compaction rotates the log to seq+1 and saves the list
+// without blocking writers.   The snapshot is fuzzy, but every key that
+// changed while it was taken has its last change in the new log, and
+// replaying that log on top of the snapshot gives the right state.
+//
+// Writers are serialized by a mutex, readers go to the skiplist directly.
+//
+
+// SyncPolicy says when the log is synced to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs every update before it returns.
+	SyncAlways SyncPolicy = iota
+	// SyncBatch syncs at the sync interval, updates in the last interval
+	// may be lost on a system crash.
+	SyncBatch
+	// SyncNone leaves syncing to the OS.   Updates survive a crash of the
+	// process, but not of the system.
+	SyncNone
+)
+
+const (
+	walSet = 1
+	walDel = 2
+
+	walHdrLen = 8
+	// sanity limit of a record, against corrupted lengths.
+	walMaxRecord = 2*maxFieldLen + 2*binary.MaxVarintLen64 + 1
+
+	snapTmp = "snap.tmp"
+)
+
+// DurableOption configures a DurableSkiplist.
+type DurableOption func(*durableConfig)
+
+type durableConfig struct {
+	sync        SyncPolicy
+	interval    time.Duration
+	compactSize int64
+	listOpts    []SkiplistOption
+}
+
+// WithSyncPolicy sets the sync policy, default is SyncAlways.
+func WithSyncPolicy(p SyncPolicy) DurableOption {
+	return func(c *durableConfig) {
+		c.sync = p
+	}
+}
+
+// WithSyncInterval sets the interval of SyncBatch, default is 10ms.
+func WithSyncInterval(d time.Duration) DurableOption {
+	return func(c *durableConfig) {
+		MustCheck(d > 0, "sync interval must be positive")
+		c.interval = d
+	}
+}
+
+// WithCompactSize starts a background compaction when the log grows past
+// size bytes, default is 64MB.   0 disables background compaction.
+func WithCompactSize(size int64) DurableOption {
+	return func(c *durableConfig) {
+		c.compactSize = size
+	}
+}
+
+// WithSkiplistOptions passes options to the underlying Skiplist.
+func WithSkiplistOptions(opts ...SkiplistOption) DurableOption {
+	return func(c *durableConfig) {
+		c.listOpts = append(c.listOpts, opts...)
+	}
+}
+
+type DurableSkiplist[K any, V any] struct {
+	list *Skiplist[K, V]
+	dir  string
+	kc   Codec[K]
+	vc   Codec[V]
+	cfg  durableConfig
+
+	// mu serializes writers and guards the log.
+	mu      sync.Mutex
+	log     *os.File
+	seq     uint64
+	size    int64
+	dirty   bool
+	buf     []byte
+	err     error
+	closed  bool
+	compact sync.Mutex
+
+	kick chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// OpenDurableSkiplist opens the list stored in dir, creating dir if
+// needed.   Keys are ordered by cmp and encoded with kc and vc, which
+// must be the same every time dir is opened.   A record torn by a crash
+// at the end of the log is dropped, a bad record elsewhere is an error.
+func OpenDurableSkiplist[K any, V any](dir string, cmp func(a, b K) int, kc Codec[K], vc Codec[V], opts ...DurableOption) (*DurableSkiplist[K, V], error) {
+	d := &DurableSkiplist[K, V]{
+		dir: dir, kc: kc, vc: vc,
+		cfg:  durableConfig{sync: SyncAlways, interval: 10 * time.Millisecond, compactSize: 64 << 20},
+		kick: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(&d.cfg)
+	}
+	d.list = NewSkipListFunc[K, V](cmp, d.cfg.listOpts...)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := d.restore(); err != nil {
+		return nil, err
+	}
+
+	if d.cfg.sync == SyncBatch {
+		d.wg.Add(1)
+		go d.syncer()
+	}
+	d.wg.Add(1)
+	go d.compactor()
+	return d, nil
+}
+
+func snapName(seq uint64) string {
+	return fmt.Sprintf("snap-%016x", seq)
+}
+
+func walName(seq uint64) string {
+	return fmt.Sprintf("wal-%016x.log", seq)
+}
+
+// restore loads the latest snapshot, replays the logs after it and opens
+// the last log for append.
+func (d *DurableSkiplist[K, V]) restore() error {
+	ents, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	var snaps, logs []uint64
+	for _, e := range ents {
+		var seq uint64
+		switch name := e.Name(); {
+		case name == snapTmp:
+			// unfinished compaction.
+			os.Remove(filepath.Join(d.dir, name))
+		case len(name) == len(snapName(0)):
+			if _, err := fmt.Sscanf(name, "snap-%x", &seq); err == nil {
+				snaps = append(snaps, seq)
+			}
+		case len(name) == len(walName(0)):
+			if _, err := fmt.Sscanf(name, "wal-%x.log", &seq); err == nil {
+				logs = append(logs, seq)
+			}
+		}
+	}
+	slices.Sort(snaps)
+	slices.Sort(logs)
+
+	if len(snaps) > 0 {
+		d.seq = snaps[len(snaps)-1]
+		f, err := os.Open(filepath.Join(d.dir, snapName(d.seq)))
+		if err != nil {
+			return err
+		}
+		_, err = d.list.LoadFrom(f, d.kc, d.vc)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("load %s: %w", snapName(d.seq), err)
+		}
+	}
+
+	// logs from the one of the snapshot on are needed until a newer
+	// snapshot is written, a compaction may have rotated the log and
+	// failed, or crashed, before writing its snapshot.
+	snapSeq := d.seq
+	for i, seq := range logs {
+		if seq < d.seq {
+			continue
+		}
+		last := i == len(logs)-1
+		if err := d.replay(seq, last); err != nil {
+			return err
+		}
+		d.seq = seq
+	}
+	d.removeBefore(snapSeq)
+
+	d.log, err = os.OpenFile(filepath.Join(d.dir, walName(d.seq)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := d.log.Stat()
+	if err != nil {
+		d.log.Close()
+		return err
+	}
+	d.size = fi.Size()
+	return syncDir(d.dir)
+}
+
+// replay applies the log seq.   A bad record that runs to the end of the
+// last log is torn by a crash and truncated, anywhere else it is an error,
+// as the records after it would be lost.
+func (d *DurableSkiplist[K, V]) replay(seq uint64, last bool) error {
+	name := filepath.Join(d.dir, walName(seq))
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	var good int64
+	var hdr [walHdrLen]byte
+	var payload []byte
+	for {
+		_, err := io.ReadFull(r, hdr[:])
+		if err == io.EOF {
+			return nil
+		}
+		// end of the record, past the file if the header is short.
+		end := fi.Size() + 1
+		if err == nil {
+			n := binary.BigEndian.Uint32(hdr[:4])
+			end = good + walHdrLen + int64(n)
+			switch {
+			case end > fi.Size():
+				err = io.ErrUnexpectedEOF
+			case n > walMaxRecord:
+				err = fmt.Errorf("record length %d too large", n)
+			default:
+				payload = slices.Grow(payload[:0], int(n))[:n]
+				_, err = io.ReadFull(r, payload)
+			}
+			if err == nil && crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(hdr[4:]) {
+				err = fmt.Errorf("checksum mismatch")
+			}
+		}
+		if err != nil {
+			if last && end >= fi.Size() {
+				// torn write of a crash.
+				return os.Truncate(name, good)
+			}
+			return fmt.Errorf("%s at offset %d: %w", walName(seq), good, noEOF(err))
+		}
+
+		if err := d.apply(payload); err != nil {
+			return fmt.Errorf("%s at offset %d: %w", walName(seq), good, err)
+		}
+		good += walHdrLen + int64(len(payload))
+	}
+}
+
+func (d *DurableSkiplist[K, V]) apply(payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("empty record")
+	}
+	op := payload[0]
+	kdata, rest, err := splitField(payload[1:])
+	if err != nil {
+		return err
+	}
+	k, err := d.kc.Decode(kdata)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case walSet:
+		vdata, _, err := splitField(rest)
+		if err != nil {
+			return err
+		}
+		v, err := d.vc.Decode(vdata)
+		if err != nil {
+			return err
+		}
+		d.list.Put(k, v)
+	case walDel:
+		d.list.Remove(k)
+	default:
+		return fmt.Errorf("bad op %d", op)
+	}
+	return nil
+}
+
+// splitField splits a field written by appendField off the front of data.
+func splitField(data []byte) ([]byte, []byte, error) {
+	n, sz := binary.Uvarint(data)
+	if sz <= 0 || n > uint64(len(data)-sz) {
+		return nil, nil, fmt.Errorf("bad field")
+	}
+	return data[sz : sz+int(n)], data[sz+int(n):], nil
+}
+
+// removeBefore removes snapshots and logs older than seq.
+func (d *DurableSkiplist[K, V]) removeBefore(seq uint64) {
+	ents, _ := os.ReadDir(d.dir)
+	for _, e := range ents {
+		var s uint64
+		name := e.Name()
+		_, err := fmt.Sscanf(name, "snap-%x", &s)
+		if err != nil {
+			_, err = fmt.Sscanf(name, "wal-%x.log", &s)
+		}
+		if err == nil && s < seq {
+			os.Remove(filepath.Join(d.dir, name))
+		}
+	}
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// write logs a record, d.mu must be held.   An error is sticky, a failed
+// write may leave a torn record, later records would be lost behind it.
+func (d *DurableSkiplist[K, V]) write(op byte, k K, v V) error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.closed {
+		return os.ErrClosed
+	}
+	buf := append(d.buf[:0], varintPad[:walHdrLen]...)
+	buf = append(buf, op)
+	buf, err := appendField(buf, k, d.kc)
+	if err == nil && op == walSet {
+		buf, err = appendField(buf, v, d.vc)
+	}
+	if err != nil {
+		// nothing written, not sticky.
+		return err
+	}
+	d.buf = buf
+	payload := buf[walHdrLen:]
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(payload, crcTable))
+
+	if _, err = d.log.Write(buf); err == nil && d.cfg.sync == SyncAlways {
+		err = d.log.Sync()
+	}
+	if err != nil {
+		d.err = err
+		return err
+	}
+	d.dirty = true
+	d.size += int64(len(buf))
+	if d.cfg.compactSize > 0 && d.size > d.cfg.compactSize {
+		select {
+		case d.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Add adds k with value v, it returns false if k is already there.
+func (d *DurableSkiplist[K, V]) Add(k K, v V) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// writers are serialized, k cannot show up after the lookup.
+	if _, ok := d.list.Lookup(k); ok {
+		return false, nil
+	}
+	if err := d.write(walSet, k, v); err != nil {
+		return false, err
+	}
+	return d.list.Add(k, v), nil
+}
+
+// Put sets the value of k, it returns true if k was added.
+func (d *DurableSkiplist[K, V]) Put(k K, v V) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.write(walSet, k, v); err != nil {
+		return false, err
+	}
+	return d.list.Put(k, v), nil
+}
+
+// Remove removes k, it returns false if k is not there.
+func (d *DurableSkiplist[K, V]) Remove(k K) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.list.Lookup(k); !ok {
+		return false, nil
+	}
+	var zero V
+	if err := d.write(walDel, k, zero); err != nil {
+		return false, err
+	}
+	return d.list.Remove(k), nil
+}
+
+// Lookup returns the value of k.
+func (d *DurableSkiplist[K, V]) Lookup(k K) (V, bool) {
+	return d.list.Lookup(k)
+}
+
+// All iterates the key value pairs in order.
+func (d *DurableSkiplist[K, V]) All() iter.Seq2[K, V] {
+	return d.list.All()
+}
+
+// Sync syncs the log to stable storage.
+func (d *DurableSkiplist[K, V]) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sync()
+}
+
+func (d *DurableSkiplist[K, V]) sync() error {
+	if d.err != nil || d.closed || !d.dirty {
+		return d.err
+	}
+	if err := d.log.Sync(); err != nil {
+		d.err = err
+		return err
+	}
+	d.dirty = false
+	return nil
+}
+
+func (d *DurableSkiplist[K, V]) syncer() {
+	defer d.wg.Done()
+	tick := time.NewTicker(d.cfg.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-tick.C:
+			d.Sync()
+		}
+	}
+}
+
+func (d *DurableSkiplist[K, V]) compactor() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-d.kick:
+			// an error is sticky or will be retried at the next kick.
+			d.Compact()
+		}
+	}
+}
+
+// rotate syncs and closes the current log and starts the next one.
+func (d *DurableSkiplist[K, V]) rotate() (uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, os.ErrClosed
+	}
+	if err := d.sync(); err != nil {
+		return 0, err
+	}
+	log, err := os.OpenFile(filepath.Join(d.dir, walName(d.seq+1)), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err == nil {
+		err = syncDir(d.dir)
+	}
+	if err != nil {
+		return 0, err
+	}
+	d.log.Close()
+	d.log = log
+	d.seq++
+	d.size = 0
+	return d.seq, nil
+}
+
+// Compact saves the list as a snapshot and removes the logs it replaces.
+// Writers are only blocked while the log is rotated.
+func (d *DurableSkiplist[K, V]) Compact() error {
+	d.compact.Lock()
+	defer d.compact.Unlock()
+	seq, err := d.rotate()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(d.dir, snapTmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = d.list.SaveTo(f, d.kc, d.vc)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(d.dir, snapName(seq)))
+	}
+	if err == nil {
+		err = syncDir(d.dir)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	d.removeBefore(seq)
+	return nil
+}
+
+// Close stops background work, syncs and closes the log.
+func (d *DurableSkiplist[K, V]) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return os.ErrClosed
+	}
+	// no more writes.
+	d.closed = true
+	d.mu.Unlock()
+
+	close(d.done)
+	d.wg.Wait()
+	// wait for a Compact called by the user.
+	d.compact.Lock()
+	defer d.compact.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty && d.err == nil {
+		d.err = d.log.Sync()
+	}
+	err := d.log.Close()
+	return errors.Join(d.err, err)
+}
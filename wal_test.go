@@ -0,0 +1,204 @@
+package gcl
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openDurable(t *testing.T, dir string, opts ...DurableOption) *DurableSkiplist[int, string] {
+	t.Helper()
+	d, err := OpenDurableSkiplist(dir, cmp.Compare[int], IntCodec[int]{}, StringCodec{}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func checkDurable(t *testing.T, d *DurableSkiplist[int, string], want map[int]string) {
+	t.Helper()
+	cnt := 0
+	for k, v := range d.All() {
+		if want[k] != v {
+			t.Fatalf("key %d has value %q, want %q", k, v, want[k])
+		}
+		cnt++
+	}
+	if cnt != len(want) {
+		t.Fatalf("%d keys, want %d", cnt, len(want))
+	}
+}
+
+func TestDurableSkiplist(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncBatch, SyncNone} {
+		t.Run(fmt.Sprintf("sync%d", policy), func(t *testing.T) {
+			dir := t.TempDir()
+			d := openDurable(t, dir, WithSyncPolicy(policy), WithSyncInterval(time.Millisecond))
+			want := make(map[int]string)
+			for i := 0; i < 100; i++ {
+				d.Add(i, "a")
+				want[i] = "a"
+			}
+			if ok, err := d.Add(1, "b"); ok || err != nil {
+				t.Errorf("add existing got %v, %v", ok, err)
+			}
+			for i := 0; i < 100; i += 3 {
+				d.Put(i, "p")
+				want[i] = "p"
+			}
+			for i := 0; i < 100; i += 5 {
+				d.Remove(i)
+				delete(want, i)
+			}
+			if ok, err := d.Remove(0); ok || err != nil {
+				t.Errorf("remove missing got %v, %v", ok, err)
+			}
+			if err := d.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			d = openDurable(t, dir)
+			defer d.Close()
+			checkDurable(t, d, want)
+		})
+	}
+}
+
+func TestDurableSkiplistTorn(t *testing.T) {
+	dir := t.TempDir()
+	d := openDurable(t, dir)
+	want := map[int]string{1: "one", 2: "two"}
+	d.Add(1, "one")
+	d.Add(2, "two")
+	d.Close()
+
+	// a crash in the middle of appending a record.
+	name := filepath.Join(dir, walName(0))
+	fi, _ := os.Stat(name)
+	f, _ := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{0, 0, 0, 9, 1, 2, 3, 4, walSet})
+	f.Close()
+
+	d = openDurable(t, dir)
+	checkDurable(t, d, want)
+	if fi2, _ := os.Stat(name); fi2.Size() != fi.Size() {
+		t.Errorf("torn record not truncated, size %d, want %d", fi2.Size(), fi.Size())
+	}
+	d.Add(3, "three")
+	want[3] = "three"
+	d.Close()
+
+	d = openDurable(t, dir)
+	checkDurable(t, d, want)
+	d.Close()
+
+	// a flipped bit in the last record is a torn record too.
+	data, _ := os.ReadFile(name)
+	data[len(data)-1] ^= 1
+	os.WriteFile(name, data, 0o644)
+	d = openDurable(t, dir)
+	delete(want, 3)
+	checkDurable(t, d, want)
+	d.Close()
+
+	// anywhere else, the records after it would be lost.
+	data, _ = os.ReadFile(name)
+	data[walHdrLen+2] ^= 1
+	os.WriteFile(name, data, 0o644)
+	if d, err := OpenDurableSkiplist(dir, cmp.Compare[int], IntCodec[int]{}, StringCodec{}); err == nil {
+		d.Close()
+		t.Errorf("opened a log corrupt in the middle")
+	}
+	if fi2, _ := os.Stat(name); fi2.Size() != int64(len(data)) {
+		t.Errorf("corrupt log truncated to %d, was %d", fi2.Size(), len(data))
+	}
+}
+
+// TestDurableSkiplistRotated reopens a list whose log was rotated by a
+// compaction that did not write its snapshot, each reopen must keep the
+// files it replayed.
+func TestDurableSkiplistRotated(t *testing.T) {
+	for _, snap := range []bool{false, true} {
+		dir := t.TempDir()
+		d := openDurable(t, dir)
+		want := map[int]string{1: "1", 2: "2"}
+		d.Add(1, "1")
+		if snap {
+			if err := d.Compact(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		d.Add(2, "2")
+		if _, err := d.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		d.Add(3, "3")
+		want[3] = "3"
+		d.Close()
+
+		for i := 0; i < 3; i++ {
+			d = openDurable(t, dir)
+			checkDurable(t, d, want)
+			d.Close()
+		}
+	}
+}
+
+func TestDurableSkiplistCompact(t *testing.T) {
+	const (
+		thCnt   = 4
+		loopCnt = 500
+	)
+	dir := t.TempDir()
+	d := openDurable(t, dir, WithSyncPolicy(SyncNone), WithCompactSize(4096))
+
+	// writers own disjoint keys, the last write of each key wins.
+	var wg sync.WaitGroup
+	for i := 0; i < thCnt; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loopCnt; j++ {
+				k := i*100 + j%100
+				if j%7 == 0 {
+					d.Remove(k)
+				} else {
+					d.Put(k, strconv.Itoa(j))
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := d.Compact(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if err := d.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := make(map[int]string)
+	for k, v := range d.All() {
+		want[k] = v
+	}
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := os.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("compaction left %d files", len(files))
+	}
+
+	d = openDurable(t, dir)
+	defer d.Close()
+	checkDurable(t, d, want)
+	if ok, err := d.Add(-1, ""); !ok || err != nil {
+		t.Errorf("add after reopen got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,296 @@
+package gcl
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+//
+// Sliding window statistics over float64 samples, on RingBuffer.
+//
+// Samples are kept in a ring, oldest first, and each statistic is updated
+// when a sample enters and when it leaves the window:
+//
+//   - count, sum, mean and variance with Welford's update and its inverse.
+//     Removing a sample much larger than the rest leaves rounding error,
+//     so they are computed again from the ring once there have been as
+//     many evictions as samples held.
+//   - min and max with monotonic deques.  The min deque holds samples in
+//     window order with increasing values, a sample is dropped from its
+//     back when a smaller one arrives, as it can never be the min again.
+//     The front is the min, and leaves when its sample leaves.
+//   - quantiles with a log bucketed sketch, as DDSketch.   A value x > 0
+//     goes to bucket ceil(log_gamma(x)), gamma = (1+a)/(1-a), whose
+//     midpoint is within relative error a of every value in it.
+//     Negative values use their own buckets.
+//
+// All updates are O(1) amortized, a quantile query sorts the buckets.
+// Samples must be finite.   WindowStats is not safe for concurrent use.
+//
+
+type wsSample struct {
+	v   float64
+	t   int64
+	seq uint64
+}
+
+type windowCore struct {
+	samples    *RingBuffer[wsSample]
+	mins, maxs *RingBuffer[wsSample]
+	seq        uint64
+
+	n         int
+	sum, mean float64
+	m2        float64
+	// evictions since sum, mean and m2 were computed from the ring.
+	evicts int
+
+	// quantile sketch.
+	lnGamma  float64
+	pos, neg map[int]int
+	zeros    int
+}
+
+func newWindowCore(size int, accuracy float64) windowCore {
+	MustCheck(accuracy > 0 && accuracy < 1, "accuracy must be in (0, 1)")
+	return windowCore{
+		samples: NewGrowingRingBuffer[wsSample](size),
+		mins:    NewGrowingRingBuffer[wsSample](size),
+		maxs:    NewGrowingRingBuffer[wsSample](size),
+		lnGamma: math.Log((1 + accuracy) / (1 - accuracy)),
+		pos:     make(map[int]int),
+		neg:     make(map[int]int),
+	}
+}
+
+func (w *windowCore) bucket(x float64) (map[int]int, int) {
+	if x > 0 {
+		return w.pos, int(math.Ceil(math.Log(x) / w.lnGamma))
+	}
+	return w.neg, int(math.Ceil(math.Log(-x) / w.lnGamma))
+}
+
+func (w *windowCore) push(x float64, t int64) {
+	MustCheck(!math.IsNaN(x) && !math.IsInf(x, 0), "sample must be finite")
+	s := wsSample{v: x, t: t, seq: w.seq}
+	w.seq++
+	w.samples.Push(s)
+
+	w.n++
+	w.sum += x
+	d := x - w.mean
+	w.mean += d / float64(w.n)
+	w.m2 += d * (x - w.mean)
+
+	for b, err := w.mins.PeekBack(); err == nil && b.v >= x; b, err = w.mins.PeekBack() {
+		w.mins.PopBack()
+	}
+	w.mins.Push(s)
+	for b, err := w.maxs.PeekBack(); err == nil && b.v <= x; b, err = w.maxs.PeekBack() {
+		w.maxs.PopBack()
+	}
+	w.maxs.Push(s)
+
+	if x == 0 {
+		w.zeros++
+	} else {
+		m, k := w.bucket(x)
+		m[k]++
+	}
+}
+
+// evict removes the oldest sample, the window must not be empty.
+func (w *windowCore) evict() {
+	s, _ := w.samples.PopFront()
+	x := s.v
+
+	w.n--
+	if w.evicts++; w.evicts >= w.n {
+		w.recompute()
+	} else {
+		w.sum -= x
+		old := w.mean
+		w.mean -= (x - old) / float64(w.n)
+		// rounding may leave it slightly negative.
+		w.m2 = max(0, w.m2-(x-old)*(x-w.mean))
+	}
+
+	if f, err := w.mins.PeekFront(); err == nil && f.seq == s.seq {
+		w.mins.PopFront()
+	}
+	if f, err := w.maxs.PeekFront(); err == nil && f.seq == s.seq {
+		w.maxs.PopFront()
+	}
+
+	if x == 0 {
+		w.zeros--
+	} else {
+		m, k := w.bucket(x)
+		if m[k]--; m[k] == 0 {
+			delete(m, k)
+		}
+	}
+}
+
+// recompute computes sum, mean and m2 from the samples in the ring.
+func (w *windowCore) recompute() {
+	w.sum, w.mean, w.m2, w.evicts = 0, 0, 0, 0
+	for i := 0; i < w.n; i++ {
+		x := w.samples.MustGet(i).v
+		w.sum += x
+		d := x - w.mean
+		w.mean += d / float64(i+1)
+		w.m2 += d * (x - w.mean)
+	}
+}
+
+// Count returns the number of samples in the window.
+func (w *windowCore) Count() int {
+	return w.n
+}
+
+// Sum returns the sum of the samples.
+func (w *windowCore) Sum() float64 {
+	return w.sum
+}
+
+// Mean returns the mean of the samples, NaN if there are none.
+func (w *windowCore) Mean() float64 {
+	if w.n == 0 {
+		return math.NaN()
+	}
+	return w.mean
+}
+
+// Variance returns the population variance of the samples, NaN if there
+// are none.
+func (w *windowCore) Variance() float64 {
+	if w.n == 0 {
+		return math.NaN()
+	}
+	return w.m2 / float64(w.n)
+}
+
+// StdDev returns the population standard deviation of the samples.
+func (w *windowCore) StdDev() float64 {
+	return math.Sqrt(w.Variance())
+}
+
+// Min returns the smallest sample, NaN if there are none.
+func (w *windowCore) Min() float64 {
+	if f, err := w.mins.PeekFront(); err == nil {
+		return f.v
+	}
+	return math.NaN()
+}
+
+// Max returns the largest sample, NaN if there are none.
+func (w *windowCore) Max() float64 {
+	if f, err := w.maxs.PeekFront(); err == nil {
+		return f.v
+	}
+	return math.NaN()
+}
+
+// Quantile returns the q-quantile of the samples, q in [0, 1], within the
+// relative accuracy of the window.   NaN if there are no samples.
+func (w *windowCore) Quantile(q float64) float64 {
+	MustCheck(q >= 0 && q <= 1, "quantile must be in [0, 1]")
+	if w.n == 0 {
+		return math.NaN()
+	}
+	// the sample at rank, counting from 0.
+	rank := int(q * float64(w.n-1))
+	gamma := math.Exp(w.lnGamma)
+	value := func(k int) float64 {
+		return 2 * math.Pow(gamma, float64(k)) / (gamma + 1)
+	}
+
+	// negative values, largest magnitude first.
+	keys := slices.Sorted(maps.Keys(w.neg))
+	for i := len(keys) - 1; i >= 0; i-- {
+		if rank -= w.neg[keys[i]]; rank < 0 {
+			return -value(keys[i])
+		}
+	}
+	if rank -= w.zeros; rank < 0 {
+		return 0
+	}
+	keys = slices.Sorted(maps.Keys(w.pos))
+	for _, k := range keys {
+		if rank -= w.pos[k]; rank < 0 {
+			return value(k)
+		}
+	}
+	return w.Max()
+}
+
+// WindowStats keeps statistics of the last size samples.
+type WindowStats struct {
+	windowCore
+	size int
+}
+
+// NewWindowStats creates statistics over the last size samples, with
+// quantiles within relative error accuracy, say 0.01.
+func NewWindowStats(size int, accuracy float64) *WindowStats {
+	MustCheck(size > 0, "window size must be positive")
+	return &WindowStats{windowCore: newWindowCore(size, accuracy), size: size}
+}
+
+// Add adds a sample, evicting the oldest one if the window is full.
+func (w *WindowStats) Add(x float64) {
+	if w.n == w.size {
+		w.evict()
+	}
+	w.push(x, 0)
+}
+
+// TimeWindowStats keeps statistics of the samples added in the last span
+// of time.   Samples expire as Add, AddAt and Advance move time forward.
+type TimeWindowStats struct {
+	windowCore
+	span time.Duration
+	// time of the latest sample.
+	last int64
+}
+
+// NewTimeWindowStats creates statistics over the samples of the last
+// span, with quantiles within relative error accuracy.
+func NewTimeWindowStats(span time.Duration, accuracy float64) *TimeWindowStats {
+	MustCheck(span > 0, "window span must be positive")
+	return &TimeWindowStats{windowCore: newWindowCore(16, accuracy), span: span, last: math.MinInt64}
+}
+
+// Add adds a sample at time.Now(), or at the latest sample's time if the
+// clock was set back.
+func (w *TimeWindowStats) Add(x float64) {
+	w.addAt(max(time.Now().UnixNano(), w.last), x)
+}
+
+// AddAt adds a sample at t.   It panics if t is before the latest sample,
+// samples are evicted in the order they are added.
+func (w *TimeWindowStats) AddAt(t time.Time, x float64) {
+	MustCheck(t.UnixNano() >= w.last, "samples must be added in time order")
+	w.addAt(t.UnixNano(), x)
+}
+
+func (w *TimeWindowStats) addAt(t int64, x float64) {
+	w.advance(t)
+	w.push(x, t)
+	w.last = t
+}
+
+// Advance evicts the samples at or before now - span.
+func (w *TimeWindowStats) Advance(now time.Time) {
+	w.advance(now.UnixNano())
+}
+
+func (w *TimeWindowStats) advance(now int64) {
+	oldest := now - int64(w.span)
+	for f, err := w.samples.PeekFront(); err == nil && f.t <= oldest; f, err = w.samples.PeekFront() {
+		w.evict()
+	}
+}
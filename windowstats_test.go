@@ -0,0 +1,196 @@
+package gcl
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+type windowStatser interface {
+	Count() int
+	Sum() float64
+	Mean() float64
+	Variance() float64
+	Min() float64
+	Max() float64
+	Quantile(q float64) float64
+}
+
+// checkWindow checks w against the samples in the window.
+func checkWindow(t *testing.T, w windowStatser, win []float64, accuracy float64) {
+	t.Helper()
+	near := func(name string, got, want float64) {
+		if math.Abs(got-want) > 1e-6*max(1, math.Abs(want)) {
+			t.Fatalf("%s got %v, want %v", name, got, want)
+		}
+	}
+	if w.Count() != len(win) {
+		t.Fatalf("count got %d, want %d", w.Count(), len(win))
+	}
+	var sum float64
+	for _, x := range win {
+		sum += x
+	}
+	mean := sum / float64(len(win))
+	var ss float64
+	for _, x := range win {
+		ss += (x - mean) * (x - mean)
+	}
+	near("sum", w.Sum(), sum)
+	near("mean", w.Mean(), mean)
+	near("variance", w.Variance(), ss/float64(len(win)))
+
+	sorted := slices.Sorted(slices.Values(win))
+	if w.Min() != sorted[0] || w.Max() != sorted[len(sorted)-1] {
+		t.Fatalf("min/max got %v %v, want %v %v", w.Min(), w.Max(), sorted[0], sorted[len(sorted)-1])
+	}
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 0.99, 1} {
+		want := sorted[int(q*float64(len(sorted)-1))]
+		if got := w.Quantile(q); math.Abs(got-want) > accuracy*math.Abs(want)+1e-12 {
+			t.Fatalf("quantile %v got %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestWindowStats(t *testing.T) {
+	const (
+		size     = 50
+		accuracy = 0.01
+	)
+	w := NewWindowStats(size, accuracy)
+	if w.Count() != 0 || !math.IsNaN(w.Mean()) || !math.IsNaN(w.Min()) || !math.IsNaN(w.Quantile(0.5)) {
+		t.Fatalf("empty window has stats")
+	}
+
+	r := rand.New(rand.NewSource(1))
+	var win []float64
+	for i := 0; i < 2000; i++ {
+		var x float64
+		switch i / 500 {
+		case 0:
+			x = r.NormFloat64()*10 + 100
+		case 1:
+			// rising, then falling, to work the deques.
+			x = float64(i)
+		case 2:
+			x = float64(2000 - i)
+		default:
+			x = float64(r.Intn(7) - 3)
+		}
+		w.Add(x)
+		if win = append(win, x); len(win) > size {
+			win = win[1:]
+		}
+		checkWindow(t, w, win, accuracy)
+	}
+}
+
+func TestTimeWindowStats(t *testing.T) {
+	const accuracy = 0.02
+	w := NewTimeWindowStats(time.Second, accuracy)
+	now := time.Unix(1000, 0)
+
+	type sample struct {
+		t time.Time
+		x float64
+	}
+	r := rand.New(rand.NewSource(2))
+	var win []sample
+	for i := 0; i < 3000; i++ {
+		// bursts and gaps, the window holds from 0 to a few hundred samples.
+		if i%500 == 499 {
+			now = now.Add(2 * time.Second)
+		} else {
+			now = now.Add(time.Duration(r.Intn(10)) * time.Millisecond)
+		}
+		x := r.ExpFloat64() * 50
+		w.AddAt(now, x)
+		win = append(win, sample{now, x})
+		for !win[0].t.After(now.Add(-time.Second)) {
+			win = win[1:]
+		}
+		xs := make([]float64, len(win))
+		for j, s := range win {
+			xs[j] = s.x
+		}
+		checkWindow(t, w, xs, accuracy)
+	}
+
+	w.Advance(now.Add(time.Second))
+	if w.Count() != 0 || w.Sum() != 0 || !math.IsNaN(w.Max()) {
+		t.Errorf("samples left after the span")
+	}
+}
+
+// TestWindowStatsOutlier checks an outlier leaves no error behind once it
+// leaves the window.
+func TestWindowStatsOutlier(t *testing.T) {
+	w := NewWindowStats(2, 0.01)
+	w.Add(1e17)
+	w.Add(1)
+	w.Add(1)
+	if w.Sum() != 2 || w.Mean() != 1 || w.Variance() != 0 {
+		t.Errorf("got sum %v mean %v variance %v", w.Sum(), w.Mean(), w.Variance())
+	}
+
+	const size = 50
+	w = NewWindowStats(size, 0.01)
+	r := rand.New(rand.NewSource(3))
+	var win []float64
+	for i := 0; i < 10*size; i++ {
+		x := r.Float64()
+		if i == size {
+			x = 1e17
+		}
+		w.Add(x)
+		if win = append(win, x); len(win) > size {
+			win = win[1:]
+		}
+		if i >= 3*size {
+			checkWindow(t, w, win, 0.01)
+		}
+	}
+}
+
+// panics returns if fn panics.
+func panics(fn func()) (ok bool) {
+	defer func() {
+		ok = recover() != nil
+	}()
+	fn()
+	return false
+}
+
+func TestWindowStatsBadSamples(t *testing.T) {
+	w := NewWindowStats(4, 0.01)
+	for _, x := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if !panics(func() { w.Add(x) }) {
+			t.Errorf("added %v", x)
+		}
+	}
+	tw := NewTimeWindowStats(time.Second, 0.01)
+	now := time.Unix(1000, 0)
+	tw.AddAt(now, 1)
+	tw.AddAt(now, 2)
+	if !panics(func() { tw.AddAt(now.Add(-time.Millisecond), 3) }) {
+		t.Errorf("added a sample before the latest")
+	}
+	if tw.Count() != 2 || w.Count() != 0 {
+		t.Errorf("counts %d %d after bad samples", tw.Count(), w.Count())
+	}
+}
+
+func BenchmarkWindowStats(b *testing.B) {
+	w := NewWindowStats(1000, 0.01)
+	r := rand.New(rand.NewSource(1))
+	xs := make([]float64, 4096)
+	for i := range xs {
+		xs[i] = r.ExpFloat64() * 100
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Add(xs[i&4095])
+	}
+}